@@ -0,0 +1,132 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SLACK_API_ENDPOINT is the root URL for Slack Web API methods.
+const SLACK_API_ENDPOINT string = "https://slack.com/api/"
+
+// The maximum number of items to request from paginated list methods.
+const list_page_size string = "200"
+
+// APIError is the error returned when a Slack Web API method responds with `"ok": false`.
+type APIError struct {
+	// Method is the name of the Slack API method that was called.
+	Method string
+	// Code is the value of the "error" property returned by the Slack API.
+	Code string
+}
+
+// Error returns a string representation of 'e'.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API method %s returned an error, %s", e.Method, e.Code)
+}
+
+// IsAPIError reports whether 'err' wraps an `APIError` whose error code is one of 'codes'. If no
+// codes are passed it reports whether 'err' wraps any `APIError`.
+func IsAPIError(err error, codes ...string) bool {
+
+	var api_err *APIError
+
+	if !errors.As(err, &api_err) {
+		return false
+	}
+
+	if len(codes) == 0 {
+		return true
+	}
+
+	for _, c := range codes {
+
+		if api_err.Code == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+// api calls the Slack API 'method' with 'args', and the broadcaster's workspace if it has one, as a form-encoded
+// POST request and returns the body of the response if the API reports success.
+func (br *SlackBroadcaster) api(ctx context.Context, method string, args url.Values) ([]byte, error) {
+
+	api_url := SLACK_API_ENDPOINT + method
+
+	args = br.withTeam(args)
+
+	args_r := strings.NewReader(args.Encode())
+
+	req, err := http.NewRequest("POST", api_url, args_r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new %s request, %w", method, err)
+	}
+
+	req.Header.Set("Content-type", "application/x-www-form-urlencoded")
+
+	rsp, err := br.call(ctx, req)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to call the Slack API, %w", err)
+	}
+
+	defer rsp.Close()
+
+	return readAPIResponse(method, rsp)
+}
+
+// readAPIResponse reads the body of a Slack API response for 'method' from 'r' and returns an `APIError`
+// if the response does not report success.
+func readAPIResponse(method string, r io.Reader) ([]byte, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read API response, %w", err)
+	}
+
+	ok_rsp := gjson.GetBytes(body, "ok")
+
+	if !ok_rsp.Bool() {
+		err_rsp := gjson.GetBytes(body, "error")
+		return nil, &APIError{Method: method, Code: err_rsp.String()}
+	}
+
+	return body, nil
+}
+
+// paginate calls the Slack API 'method' with 'args' using 'api', following "next_cursor" pointers, and invokes 'cb'
+// for each page of results.
+func paginate(ctx context.Context, api func(context.Context, string, url.Values) ([]byte, error), method string, args url.Values, cb func([]byte)) error {
+
+	args.Set("limit", list_page_size)
+
+	for {
+
+		body, err := api(ctx, method, args)
+
+		if err != nil {
+			return err
+		}
+
+		cb(body)
+
+		cursor := gjson.GetBytes(body, "response_metadata.next_cursor").String()
+
+		if cursor == "" {
+			break
+		}
+
+		args.Set("cursor", cursor)
+	}
+
+	return nil
+}
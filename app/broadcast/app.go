@@ -0,0 +1,206 @@
+// Package broadcast provides methods for implementing a command line tool for "broadcasting" messages
+// which supports the Slack-specific -dry-run and -metrics flags.
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-slack"
+	"github.com/aaronland/go-uid"
+	"github.com/sfomuseum/go-flags/flagset"
+	"image"
+	"io"
+	"log"
+	"net/url"
+	"os"
+)
+
+func Run(ctx context.Context, logger *log.Logger) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs, logger)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet, logger *log.Logger) error {
+
+	flagset.Parse(fs)
+
+	if metrics_uri != "" {
+
+		stop, err := slack.StartMetrics(ctx, metrics_uri)
+
+		if err != nil {
+			return fmt.Errorf("Failed to start metrics, %w", err)
+		}
+
+		defer stop()
+	}
+
+	uris := []string(broadcaster_uris)
+
+	if dry_run {
+
+		for idx, uri := range uris {
+
+			dry_uri, err := dryRunURI(uri)
+
+			if err != nil {
+				return err
+			}
+
+			uris[idx] = dry_uri
+		}
+	}
+
+	broadcasters := make([]broadcaster.Broadcaster, len(uris))
+
+	for idx, uri := range uris {
+
+		b, err := broadcaster.NewBroadcaster(ctx, uri)
+
+		if err != nil {
+			return fmt.Errorf("Failed to create broadcaster for '%s', %w", uri, err)
+		}
+
+		broadcasters[idx] = b
+	}
+
+	// Broadcasters like digests and outboxes only finish delivering messages when they are closed so make sure
+	// that happens even if something goes wrong. Closing them more than once is safe.
+
+	defer closeBroadcasters(broadcasters)
+
+	br, err := broadcaster.NewMultiBroadcaster(ctx, broadcasters...)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	br.SetLogger(ctx, logger)
+
+	msg := &broadcaster.Message{
+		Title: title,
+		Body:  body,
+	}
+
+	count_images := len(image_paths)
+
+	if count_images > 0 {
+
+		msg.Images = make([]image.Image, count_images)
+
+		for idx, path := range image_paths {
+
+			r, err := os.Open(path)
+
+			if err != nil {
+				return fmt.Errorf("Failed to open image %s, %w", path, err)
+			}
+
+			defer r.Close()
+
+			im, _, err := image.Decode(r)
+
+			if err != nil {
+				return fmt.Errorf("Failed to decode image %s, %w", path, err)
+			}
+
+			msg.Images[idx] = im
+		}
+	}
+
+	id, err := br.BroadcastMessage(ctx, msg)
+
+	if err != nil {
+		return fmt.Errorf("Failed to broadcast message, %w", err)
+	}
+
+	err = closeBroadcasters(broadcasters)
+
+	if err != nil {
+		return fmt.Errorf("Failed to close broadcaster, %w", err)
+	}
+
+	fmt.Println(resolveUID(id).String())
+	return nil
+}
+
+// closeBroadcasters closes each of 'broadcasters' which implements the `io.Closer` interface, which causes
+// digests to be posted and outbox workers to be stopped.
+func closeBroadcasters(broadcasters []broadcaster.Broadcaster) error {
+
+	errs := make([]error, 0)
+
+	for _, b := range broadcasters {
+
+		cl, ok := b.(io.Closer)
+
+		if !ok {
+			continue
+		}
+
+		err := cl.Close()
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// resolveUID returns 'id' with any `slack.DigestUID` instances, whose digests will have been posted once
+// the broadcasters have been closed, replaced by the UID of the digest post.
+func resolveUID(id uid.UID) uid.UID {
+
+	switch u := id.(type) {
+	case *slack.DigestUID:
+
+		digest_id, ok := u.Resolved()
+
+		if ok {
+			return digest_id
+		}
+
+	case *uid.MultiUID:
+
+		ids, ok := u.Value().([]uid.UID)
+
+		if !ok {
+			return id
+		}
+
+		resolved := make([]uid.UID, len(ids))
+
+		for idx, i := range ids {
+			resolved[idx] = resolveUID(i)
+		}
+
+		return uid.NewMultiUID(context.Background(), resolved...)
+	}
+
+	return id
+}
+
+// dryRunURI returns 'uri' with the "?dry-run=true" parameter set. Only slack:// URIs are supported since other
+// broadcasters have no way to render messages without sending them.
+func dryRunURI(uri string) (string, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse broadcaster URI, %w", err)
+	}
+
+	if u.Scheme != "slack" {
+		return "", fmt.Errorf("The -dry-run flag is not supported by %s:// broadcasters", u.Scheme)
+	}
+
+	q := u.Query()
+	q.Set("dry-run", "true")
+
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
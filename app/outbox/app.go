@@ -0,0 +1,138 @@
+// Package outbox provides methods for implementing a command line tool for inspecting, retrying and
+// purging messages waiting to be delivered from an outbox.
+package outbox
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-slack"
+	"github.com/sfomuseum/go-flags/flagset"
+	"log"
+	"time"
+)
+
+func Run(ctx context.Context, logger *log.Logger) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs, logger)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet, logger *log.Logger) error {
+
+	flagset.Parse(fs)
+
+	if metrics_uri != "" {
+
+		stop, err := slack.StartMetrics(ctx, metrics_uri)
+
+		if err != nil {
+			return fmt.Errorf("Failed to start metrics, %w", err)
+		}
+
+		defer stop()
+	}
+
+	if outbox_root == "" {
+		return fmt.Errorf("Missing -outbox flag")
+	}
+
+	if !list && len(retry_ids) == 0 && !retry_all && len(purge_ids) == 0 && !purge_all {
+		return fmt.Errorf("Nothing to do, please specify -list, -retry, -retry-all, -purge or -purge-all")
+	}
+
+	o, err := slack.NewOutbox(ctx, outbox_root)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create outbox, %w", err)
+	}
+
+	o.SetLogger(logger)
+
+	if len(retry_ids) > 0 || retry_all {
+
+		if broadcaster_uri == "" {
+			return fmt.Errorf("Missing -broadcaster flag")
+		}
+
+		br, err := broadcaster.NewBroadcaster(ctx, broadcaster_uri)
+
+		if err != nil {
+			return fmt.Errorf("Failed to create broadcaster, %w", err)
+		}
+
+		br.SetLogger(ctx, logger)
+
+		ids := retry_ids
+
+		if retry_all {
+
+			items, err := o.List(ctx)
+
+			if err != nil {
+				return err
+			}
+
+			ids = make([]string, len(items))
+
+			for idx, item := range items {
+				ids[idx] = item.ID
+			}
+		}
+
+		// Items are only delivered if the broadcaster posts to the same channel, and workspace, that they were
+		// added for. Items which fail when retried manually are eligible to be retried again immediately
+		backoff := func(int) time.Duration {
+			return 0
+		}
+
+		for _, id := range ids {
+
+			msg_id, err := o.Deliver(ctx, br, id, backoff)
+
+			if err != nil {
+				logger.Printf("Failed to retry outbox item %s, %v\n", id, err)
+				continue
+			}
+
+			logger.Printf("Delivered outbox item %s as %s\n", id, msg_id.String())
+		}
+	}
+
+	for _, id := range purge_ids {
+
+		err := o.Remove(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("Removed outbox item %s\n", id)
+	}
+
+	if purge_all {
+
+		count, err := o.Purge(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("Removed %d outbox items\n", count)
+	}
+
+	if list {
+
+		items, err := o.List(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			fmt.Printf("%s\t%s\t%d\t%s\t%s\t%s\n", item.ID, item.Created.Format(time.RFC3339), item.Attempts, item.NextAttempt.Format(time.RFC3339), item.Title, item.LastError)
+		}
+	}
+
+	return nil
+}
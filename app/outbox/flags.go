@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"flag"
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/multi"
+)
+
+// The path to the outbox directory.
+var outbox_root string
+
+// A valid broadcaster URI used to deliver retried messages.
+var broadcaster_uri string
+
+// List the items in the outbox.
+var list bool
+
+// Zero or more outbox item IDs to retry.
+var retry_ids multi.MultiString
+
+// Retry all the items in the outbox.
+var retry_all bool
+
+// Zero or more outbox item IDs to remove.
+var purge_ids multi.MultiString
+
+// Remove all the items in the outbox.
+var purge_all bool
+
+// An optional metrics exporter URI used to report Slack API metrics.
+var metrics_uri string
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("outbox")
+
+	fs.StringVar(&outbox_root, "outbox", "", "The path to the outbox directory.")
+	fs.StringVar(&broadcaster_uri, "broadcaster", "", "A valid broadcaster URI used to deliver retried messages. This should not itself define an ?outbox= parameter. Items are only retried if the broadcaster posts to the channel, and workspace, they were added for.")
+	fs.BoolVar(&list, "list", false, "List the items in the outbox.")
+	fs.Var(&retry_ids, "retry", "Zero or more outbox item IDs to retry.")
+	fs.BoolVar(&retry_all, "retry-all", false, "Retry all the items in the outbox.")
+	fs.Var(&purge_ids, "purge", "Zero or more outbox item IDs to remove.")
+	fs.BoolVar(&purge_all, "purge-all", false, "Remove all the items in the outbox.")
+	fs.StringVar(&metrics_uri, "metrics", "", "An optional metrics exporter URI (for example \"log://?interval=10s\") used to report Slack API metrics.")
+
+	return fs
+}
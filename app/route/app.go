@@ -0,0 +1,60 @@
+// Package route provides methods for implementing a command line tool for testing which channels a message
+// would be posted to by a set of routing rules.
+package route
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-broadcaster-slack"
+	"github.com/sfomuseum/go-flags/flagset"
+	"log"
+	"time"
+)
+
+func Run(ctx context.Context, logger *log.Logger) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs, logger)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet, logger *log.Logger) error {
+
+	flagset.Parse(fs)
+
+	if rules_uri == "" {
+		return fmt.Errorf("Missing -rules flag")
+	}
+
+	if title == "" && body == "" {
+		return fmt.Errorf("Nothing to route, please specify -title or -body")
+	}
+
+	// Rules are loaded the same way as slack-route:// broadcasters so that both accept the same URIs
+
+	rt_ctx, rt_cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer rt_cancel()
+
+	rules, err := slack.LoadRouteRules(rt_ctx, rules_uri)
+
+	if err != nil {
+		return err
+	}
+
+	msg := &broadcaster.Message{
+		Title: title,
+		Body:  body,
+	}
+
+	route := rules.Route(msg)
+
+	if len(route.Channels) == 0 {
+		return fmt.Errorf("Message does not match any rules and there are no default channels")
+	}
+
+	for _, channel := range route.Channels {
+		fmt.Printf("%s\t%s\n", channel, route.Rules[channel])
+	}
+
+	return nil
+}
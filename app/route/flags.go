@@ -0,0 +1,26 @@
+package route
+
+import (
+	"flag"
+	"github.com/sfomuseum/go-flags/flagset"
+)
+
+// A valid gocloud.dev/runtimevar URI which dereferences to a JSON-encoded routing rules document.
+var rules_uri string
+
+// The title of the message to route.
+var title string
+
+// The body of the message to route.
+var body string
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("route")
+
+	fs.StringVar(&rules_uri, "rules", "", "A valid gocloud.dev/runtimevar URI which dereferences to a JSON-encoded routing rules document.")
+	fs.StringVar(&title, "title", "", "The title of the message to route.")
+	fs.StringVar(&body, "body", "", "The body of the message to route.")
+
+	return fs
+}
@@ -0,0 +1,82 @@
+// Package scheduled provides methods for implementing a command line tool for listing and cancelling
+// messages scheduled for delivery to a Slack channel.
+package scheduled
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/aaronland/go-broadcaster-slack"
+	"github.com/sfomuseum/go-flags/flagset"
+	"log"
+	"time"
+)
+
+func Run(ctx context.Context, logger *log.Logger) error {
+	fs := DefaultFlagSet()
+	return RunWithFlagSet(ctx, fs, logger)
+}
+
+func RunWithFlagSet(ctx context.Context, fs *flag.FlagSet, logger *log.Logger) error {
+
+	flagset.Parse(fs)
+
+	if metrics_uri != "" {
+
+		stop, err := slack.StartMetrics(ctx, metrics_uri)
+
+		if err != nil {
+			return fmt.Errorf("Failed to start metrics, %w", err)
+		}
+
+		defer stop()
+	}
+
+	if broadcaster_uri == "" {
+		return fmt.Errorf("Missing -broadcaster flag")
+	}
+
+	if !list && len(cancel_ids) == 0 {
+		return fmt.Errorf("Nothing to do, please specify -list or -cancel")
+	}
+
+	b, err := slack.NewSlackBroadcaster(ctx, broadcaster_uri)
+
+	if err != nil {
+		return fmt.Errorf("Failed to create broadcaster, %w", err)
+	}
+
+	br, ok := b.(*slack.SlackBroadcaster)
+
+	if !ok {
+		return fmt.Errorf("Broadcaster does not support scheduled messages")
+	}
+
+	br.SetLogger(ctx, logger)
+
+	for _, id := range cancel_ids {
+
+		err := br.CancelScheduled(ctx, id)
+
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("Cancelled scheduled message %s\n", id)
+	}
+
+	if list {
+
+		messages, err := br.ListScheduled(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		for _, m := range messages {
+			fmt.Printf("%s/%s\t%s\t%s\n", m.Channel, m.ID, m.PostAt.Format(time.RFC3339), m.Text)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package scheduled
+
+import (
+	"flag"
+	"github.com/sfomuseum/go-flags/flagset"
+	"github.com/sfomuseum/go-flags/multi"
+)
+
+// A valid slack:// broadcaster URI.
+var broadcaster_uri string
+
+// List the messages scheduled for delivery to the channel.
+var list bool
+
+// Zero or more scheduled message IDs to cancel.
+var cancel_ids multi.MultiString
+
+// An optional metrics exporter URI used to report Slack API metrics.
+var metrics_uri string
+
+func DefaultFlagSet() *flag.FlagSet {
+
+	fs := flagset.NewFlagSet("scheduled")
+
+	fs.StringVar(&broadcaster_uri, "broadcaster", "", "A valid slack:// broadcaster URI.")
+	fs.BoolVar(&list, "list", false, "List the messages scheduled for delivery to the channel.")
+	fs.Var(&cancel_ids, "cancel", "Zero or more scheduled message IDs to cancel.")
+	fs.StringVar(&metrics_uri, "metrics", "", "An optional metrics exporter URI (for example \"log://?interval=10s\") used to report Slack API metrics.")
+
+	return fs
+}
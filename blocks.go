@@ -0,0 +1,77 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// The maximum length of a Block Kit "header" block's text.
+const blocks_max_header_length int = 150
+
+// The maximum length of a Block Kit "section" block's text.
+const blocks_max_section_length int = 3000
+
+type block struct {
+	Type string     `json:"type"`
+	Text *blockText `json:"text,omitempty"`
+}
+
+type blockText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// renderBlocks returns a JSON-encoded list of Block Kit blocks for 'title' and 'sections'. The title is rendered as
+// a "header" block, which Slack treats as plain text and never parses for mentions, and each of 'sections', which
+// are expected to have already been escaped according to a `MentionPolicy`, is rendered as a "mrkdwn" section block.
+func renderBlocks(title string, sections []string) (string, error) {
+
+	blocks := make([]*block, 0)
+
+	if title != "" {
+
+		blocks = append(blocks, &block{
+			Type: "header",
+			Text: &blockText{
+				Type: "plain_text",
+				Text: truncateString(title, blocks_max_header_length),
+			},
+		})
+	}
+
+	for _, text := range sections {
+
+		if text == "" {
+			continue
+		}
+
+		blocks = append(blocks, &block{
+			Type: "section",
+			Text: &blockText{
+				Type: "mrkdwn",
+				Text: truncateString(text, blocks_max_section_length),
+			},
+		})
+	}
+
+	enc, err := json.Marshal(blocks)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode blocks, %w", err)
+	}
+
+	return string(enc), nil
+}
+
+// truncateString returns 'str' truncated to at most 'max' characters, replacing the last character
+// with an ellipsis if it was truncated.
+func truncateString(str string, max int) string {
+
+	if utf8.RuneCountInString(str) <= max {
+		return str
+	}
+
+	r := []rune(str)
+	return string(r[:max-1]) + "…"
+}
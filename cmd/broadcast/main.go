@@ -2,8 +2,7 @@ package main
 
 import (
 	"context"
-	_ "github.com/aaronland/go-broadcaster-slack"
-	"github.com/aaronland/go-broadcaster/app/broadcast"
+	"github.com/aaronland/go-broadcaster-slack/app/broadcast"
 	"log"
 )
 
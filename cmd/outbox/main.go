@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"github.com/aaronland/go-broadcaster-slack/app/outbox"
+	"log"
+)
+
+func main() {
+
+	ctx := context.Background()
+	logger := log.Default()
+
+	err := outbox.Run(ctx, logger)
+
+	if err != nil {
+		logger.Fatalf("Failed to run outbox application, %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"github.com/aaronland/go-broadcaster-slack/app/route"
+	"log"
+)
+
+func main() {
+
+	ctx := context.Background()
+	logger := log.Default()
+
+	err := route.Run(ctx, logger)
+
+	if err != nil {
+		logger.Fatalf("Failed to run route application, %v", err)
+	}
+}
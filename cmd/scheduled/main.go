@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"github.com/aaronland/go-broadcaster-slack/app/scheduled"
+	"log"
+)
+
+func main() {
+
+	ctx := context.Background()
+	logger := log.Default()
+
+	err := scheduled.Run(ctx, logger)
+
+	if err != nil {
+		logger.Fatalf("Failed to run scheduled application, %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package slack
+
+import (
+	"context"
+	"time"
+)
+
+// contextKey is the type used for per-message options stored in a `context.Context`.
+type contextKey string
+
+const post_at_key contextKey = "slack:post_at"
+
+const ephemeral_user_key contextKey = "slack:ephemeral_user"
+
+const identity_key contextKey = "slack:identity"
+
+const post_options_key contextKey = "slack:post_options"
+
+const metadata_key contextKey = "slack:metadata"
+
+const idempotency_key_key contextKey = "slack:idempotency_key"
+
+// WithPostAt returns a copy of 'ctx' which will cause messages broadcast by a `SlackBroadcaster` instance
+// to be scheduled for delivery at 't' using the chat.scheduleMessage API method.
+func WithPostAt(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, post_at_key, t)
+}
+
+// postAtFromContext returns the time a message should be delivered at, if present in 'ctx'.
+func postAtFromContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(post_at_key).(time.Time)
+	return t, ok
+}
+
+// WithEphemeralUser returns a copy of 'ctx' which will cause messages broadcast by a `SlackBroadcaster` instance
+// to be posted as ephemeral messages, using the chat.postEphemeral API method, visible only to 'user'.
+func WithEphemeralUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, ephemeral_user_key, user)
+}
+
+// ephemeralUserFromContext returns the ID of the user an ephemeral message should be posted for, if present in 'ctx'.
+func ephemeralUserFromContext(ctx context.Context) (string, bool) {
+	user, ok := ctx.Value(ephemeral_user_key).(string)
+	return user, ok && user != ""
+}
+
+// WithIdentity returns a copy of 'ctx' which will cause messages broadcast by a `SlackBroadcaster` instance
+// to be posted using the username and icon defined by 'id', overriding any identity defined by the broadcaster.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identity_key, id)
+}
+
+// identityFromContext returns the `Identity` to post messages as, if present in 'ctx'.
+func identityFromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identity_key).(*Identity)
+	return id, ok && id != nil
+}
+
+// WithPostOptions returns a copy of 'ctx' which will cause messages broadcast by a `SlackBroadcaster` instance
+// to be posted using 'opts', overriding any options defined by the broadcaster.
+func WithPostOptions(ctx context.Context, opts *PostOptions) context.Context {
+	return context.WithValue(ctx, post_options_key, opts)
+}
+
+// postOptionsFromContext returns the `PostOptions` to post messages with, if present in 'ctx'.
+func postOptionsFromContext(ctx context.Context) (*PostOptions, bool) {
+	opts, ok := ctx.Value(post_options_key).(*PostOptions)
+	return opts, ok && opts != nil
+}
+
+// WithMetadata returns a copy of 'ctx' which will cause messages broadcast by a `SlackBroadcaster` instance
+// to be posted with 'md' attached as Slack message metadata.
+func WithMetadata(ctx context.Context, md *Metadata) context.Context {
+	return context.WithValue(ctx, metadata_key, md)
+}
+
+// WithMetadataJSON returns a copy of 'ctx' which will cause messages broadcast by a `SlackBroadcaster` instance
+// to be posted with 'body', a JSON object with "event_type" and "event_payload" properties, attached as Slack
+// message metadata.
+func WithMetadataJSON(ctx context.Context, body []byte) (context.Context, error) {
+
+	md, err := NewMetadataFromJSON(body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return WithMetadata(ctx, md), nil
+}
+
+// metadataFromContext returns the `Metadata` to attach to messages, if present in 'ctx'.
+func metadataFromContext(ctx context.Context) (*Metadata, bool) {
+	md, ok := ctx.Value(metadata_key).(*Metadata)
+	return md, ok && md != nil
+}
+
+// WithIdempotencyKey returns a copy of 'ctx' which will cause a `SlackBroadcaster` instance configured with
+// a key store to use 'key', rather than a hash of the message, to identify duplicate messages.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotency_key_key, key)
+}
+
+// idempotencyKeyFromContext returns the key used to identify duplicate messages, if present in 'ctx'.
+func idempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotency_key_key).(string)
+	return key, ok && key != ""
+}
+
+// hasMessageOptions reports whether 'ctx' contains any per-message options.
+func hasMessageOptions(ctx context.Context) bool {
+	return newOutboxMessageOptions(ctx) != nil
+}
@@ -0,0 +1,361 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-uid"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The default maximum number of messages to collect before a digest is posted.
+const default_digest_max int = 100
+
+// The title used to group messages which do not have a title in a digest.
+const digest_untitled string = "Untitled"
+
+// digestBatch is a set of messages which will be posted together as a single digest.
+type digestBatch struct {
+	id       int64
+	messages []*broadcaster.Message
+	done     chan bool
+	uid      uid.UID
+	err      error
+}
+
+// DigestUID implements the `uid.UID` interface for a message which has been added to a digest. Once the digest
+// has been posted the UID of the digest post can be retrieved using the `Wait` or `Resolved` methods.
+type DigestUID struct {
+	uid.UID
+	batch *digestBatch
+	index int
+}
+
+// Value returns the string representation of 'u'.
+func (u *DigestUID) Value() any {
+	return u.String()
+}
+
+// String returns 'u' in the form of "digest:{DIGEST_ID}#{INDEX}" where index is the position of the
+// message in the digest.
+func (u *DigestUID) String() string {
+	return fmt.Sprintf("digest:%d#%d", u.batch.id, u.index)
+}
+
+// Wait blocks until the digest containing the message has been posted, or 'ctx' is cancelled, and returns
+// the UID of the digest post.
+func (u *DigestUID) Wait(ctx context.Context) (uid.UID, error) {
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-u.batch.done:
+		return u.batch.uid, u.batch.err
+	}
+}
+
+// Resolved returns the UID of the digest post and a boolean flag signaling whether the digest containing
+// the message has been posted successfully.
+func (u *DigestUID) Resolved() (uid.UID, bool) {
+
+	select {
+	case <-u.batch.done:
+		return u.batch.uid, u.batch.err == nil
+	default:
+		return nil, false
+	}
+}
+
+// DigestBroadcaster implements the `broadcaster.Broadcaster` interface by collecting messages in memory and
+// periodically posting them, using another `broadcaster.Broadcaster`, as a single combined message.
+type DigestBroadcaster struct {
+	broadcaster.Broadcaster
+	target       broadcaster.Broadcaster
+	window       time.Duration
+	max          int
+	logger       *log.Logger
+	mu           *sync.Mutex
+	batch        *digestBatch
+	next_id      int64
+	flush        chan bool
+	cancel       context.CancelFunc
+	done         chan bool
+	once         sync.Once
+	closed       bool
+	close_target bool
+}
+
+// NewDigestBroadcaster returns a new `DigestBroadcaster` instance which posts the messages it has collected
+// using 'target' every 'window' or as soon as 'max' messages have been collected. Callers should invoke the
+// `Close` method to post any remaining messages.
+func NewDigestBroadcaster(ctx context.Context, target broadcaster.Broadcaster, window time.Duration, max int) (*DigestBroadcaster, error) {
+
+	if window <= 0 {
+		return nil, fmt.Errorf("Digest window must be greater than zero")
+	}
+
+	if max <= 0 {
+		return nil, fmt.Errorf("Maximum digest size must be greater than zero")
+	}
+
+	worker_ctx, cancel := context.WithCancel(context.Background())
+
+	br := &DigestBroadcaster{
+		target:  target,
+		window:  window,
+		max:     max,
+		logger:  log.Default(),
+		mu:      new(sync.Mutex),
+		next_id: time.Now().Unix(),
+		flush:   make(chan bool, 1),
+		cancel:  cancel,
+		done:    make(chan bool),
+	}
+
+	go br.run(worker_ctx)
+
+	return br, nil
+}
+
+// BroadcastMessage adds 'msg' to the current digest and returns a `DigestUID`. Messages containing images can
+// not be combined and are broadcast immediately. So are messages broadcast with per-message options stored in 'ctx',
+// for example using `WithEphemeralUser` or `WithPostAt`, since those options can not be applied to a digest.
+func (br *DigestBroadcaster) BroadcastMessage(ctx context.Context, msg *broadcaster.Message) (uid.UID, error) {
+
+	if len(msg.Images) > 0 || hasMessageOptions(ctx) {
+		return br.target.BroadcastMessage(ctx, msg)
+	}
+
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	if br.closed {
+		return nil, fmt.Errorf("Digest broadcaster has been closed")
+	}
+
+	if br.batch == nil {
+
+		br.next_id += 1
+
+		br.batch = &digestBatch{
+			id:       br.next_id,
+			messages: make([]*broadcaster.Message, 0),
+			done:     make(chan bool),
+		}
+	}
+
+	br.batch.messages = append(br.batch.messages, msg)
+
+	u := &DigestUID{
+		batch: br.batch,
+		index: len(br.batch.messages) - 1,
+	}
+
+	if len(br.batch.messages) >= br.max {
+
+		select {
+		case br.flush <- true:
+		default:
+			// A flush has already been requested
+		}
+	}
+
+	return u, nil
+}
+
+// BroadcastSlackMessage broadcasts 'msg' and its Slack-specific properties. Since metadata can not be applied to a
+// digest, messages with metadata are broadcast immediately in the same way as messages with per-message options.
+func (br *DigestBroadcaster) BroadcastSlackMessage(ctx context.Context, msg *Message) (uid.UID, error) {
+	return broadcastSlackMessage(ctx, br, msg)
+}
+
+// SetLogger assigns 'logger' to 'br' and the broadcaster it posts digests with. It is safe to call while messages are being collected or posted.
+func (br *DigestBroadcaster) SetLogger(ctx context.Context, logger *log.Logger) error {
+
+	br.mu.Lock()
+	br.logger = logger
+	br.mu.Unlock()
+
+	return br.target.SetLogger(ctx, logger)
+}
+
+// Flush posts the messages collected so far, if any, as a single digest and returns the UID of the digest post.
+func (br *DigestBroadcaster) Flush(ctx context.Context) (uid.UID, error) {
+
+	br.mu.Lock()
+	batch := br.batch
+	br.batch = nil
+	br.mu.Unlock()
+
+	if batch == nil {
+		return nil, nil
+	}
+
+	defer close(batch.done)
+
+	msg := renderDigest(batch.messages)
+
+	id, err := br.target.BroadcastMessage(ctx, msg)
+
+	if err != nil {
+		batch.err = fmt.Errorf("Failed to post digest %d, %w", batch.id, err)
+		recordDropped(ctx, DROPPED_DIGEST, len(batch.messages))
+		return nil, batch.err
+	}
+
+	batch.uid = id
+	return id, nil
+}
+
+// Close posts any remaining messages and stops the background worker. If 'br' was created by `NewSlackBroadcaster`
+// or `NewSlackBroadcasterWithOptions` the broadcaster it posts digests with is also closed.
+func (br *DigestBroadcaster) Close() error {
+
+	var err error
+
+	br.once.Do(func() {
+
+		br.mu.Lock()
+		br.closed = true
+		br.mu.Unlock()
+
+		br.cancel()
+		<-br.done
+
+		_, err = br.Flush(context.Background())
+
+		if br.close_target {
+
+			cl, ok := br.target.(io.Closer)
+
+			if ok {
+
+				cl_err := cl.Close()
+
+				if err == nil {
+					err = cl_err
+				}
+			}
+		}
+	})
+
+	return err
+}
+
+// run posts a digest every window, or whenever the maximum number of messages has been collected, until 'ctx' is cancelled.
+func (br *DigestBroadcaster) run(ctx context.Context) {
+
+	defer close(br.done)
+
+	ticker := time.NewTicker(br.window)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// pass
+		case <-br.flush:
+			// pass
+		}
+
+		// Digests are posted with their own context so that closing 'br' does not interrupt a post in progress
+
+		_, err := br.Flush(context.Background())
+
+		if err != nil {
+			br.mu.Lock()
+			logger := br.logger
+			br.mu.Unlock()
+
+			logger.Printf("%v\n", err)
+		}
+	}
+}
+
+// renderDigest returns a single message combining 'messages'. Messages are grouped by title, in the order
+// each title was first seen, and identical bodies within a group are collapsed in to a single line with a count.
+func renderDigest(messages []*broadcaster.Message) *broadcaster.Message {
+
+	titles := make([]string, 0)
+	groups := make(map[string][]string)
+	counts := make(map[string]map[string]int)
+
+	for _, m := range messages {
+
+		title := strings.TrimSpace(m.Title)
+
+		if title == "" {
+			title = digest_untitled
+		}
+
+		_, ok := groups[title]
+
+		if !ok {
+			titles = append(titles, title)
+			groups[title] = make([]string, 0)
+			counts[title] = make(map[string]int)
+		}
+
+		body := strings.TrimSpace(m.Body)
+
+		if counts[title][body] == 0 {
+			groups[title] = append(groups[title], body)
+		}
+
+		counts[title][body] += 1
+	}
+
+	var sb strings.Builder
+
+	for idx, title := range titles {
+
+		if idx > 0 {
+			sb.WriteString("\n")
+		}
+
+		total := 0
+
+		for _, c := range counts[title] {
+			total += c
+		}
+
+		sb.WriteString(fmt.Sprintf("*%s* (%d)\n", title, total))
+
+		for _, body := range groups[title] {
+
+			if body == "" {
+				continue
+			}
+
+			line := strings.ReplaceAll(body, "\n", " ")
+
+			c := counts[title][body]
+
+			if c > 1 {
+				line = fmt.Sprintf("%s (×%d)", line, c)
+			}
+
+			sb.WriteString(fmt.Sprintf("• %s\n", line))
+		}
+	}
+
+	label := "messages"
+
+	if len(messages) == 1 {
+		label = "message"
+	}
+
+	msg := &broadcaster.Message{
+		Title: fmt.Sprintf("Digest: %d %s", len(messages), label),
+		Body:  strings.TrimSpace(sb.String()),
+	}
+
+	return msg
+}
@@ -0,0 +1,89 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aaronland/go-uid"
+	"sync/atomic"
+	"time"
+)
+
+// dry_run_seq is used to derive unique synthetic timestamps for messages which are not posted.
+var dry_run_seq int64
+
+// DryRunUID implements the `uid.UID` interface for a message which was rendered, but not posted, because
+// the broadcaster is in dry-run mode.
+type DryRunUID struct {
+	uid.UID
+	channel string
+	ts      string
+}
+
+// NewDryRunUID returns a new `DryRunUID` for a message which would have been posted to 'channel' with a
+// synthetic timestamp 'ts'.
+func NewDryRunUID(ctx context.Context, channel string, ts string) (uid.UID, error) {
+
+	if channel == "" {
+		return nil, fmt.Errorf("Missing channel")
+	}
+
+	if ts == "" {
+		return nil, fmt.Errorf("Missing timestamp")
+	}
+
+	u := &DryRunUID{
+		channel: channel,
+		ts:      ts,
+	}
+
+	return u, nil
+}
+
+// Channel returns the channel that the message would have been posted to.
+func (u *DryRunUID) Channel() string {
+	return u.channel
+}
+
+// Timestamp returns the synthetic Slack timestamp ("ts") of the message.
+func (u *DryRunUID) Timestamp() string {
+	return u.ts
+}
+
+// Value returns the string representation of 'u'.
+func (u *DryRunUID) Value() any {
+	return u.String()
+}
+
+// String returns 'u' in the form of "dry-run:{CHANNEL}/{TIMESTAMP}".
+func (u *DryRunUID) String() string {
+	return fmt.Sprintf("dry-run:%s/%s", u.channel, u.ts)
+}
+
+// dryRun writes the API request that 'p' describes to the broadcaster's dry-run writer, or logger, instead of
+// sending it and returns a `DryRunUID` with a synthetic timestamp.
+func (br *SlackBroadcaster) dryRun(ctx context.Context, p *payload) (uid.UID, error) {
+
+	body, err := json.MarshalIndent(renderPayload(p), "", "  ")
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to render payload, %w", err)
+	}
+
+	if br.dry_run_writer != nil {
+
+		_, err = fmt.Fprintf(br.dry_run_writer, "%s\n", body)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to write payload, %w", err)
+		}
+
+	} else {
+		br.logf("[dry-run] %s\n", body)
+	}
+
+	seq := atomic.AddInt64(&dry_run_seq, 1)
+	ts := fmt.Sprintf("%d.%06d", time.Now().Unix(), seq%1000000)
+
+	return NewDryRunUID(ctx, br.channel, ts)
+}
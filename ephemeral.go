@@ -0,0 +1,79 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-uid"
+)
+
+// EphemeralMessageUID implements the `uid.UID` interface for an ephemeral message posted to a Slack channel
+// which is only visible to a single user. Ephemeral messages are not persisted by Slack and can not be updated,
+// reacted to or replied to.
+type EphemeralMessageUID struct {
+	uid.UID
+	channel string
+	user    string
+	ts      string
+}
+
+// NewEphemeralMessageUID returns a new `EphemeralMessageUID` for the ephemeral message with timestamp 'ts'
+// posted to 'channel' for 'user'.
+func NewEphemeralMessageUID(ctx context.Context, channel string, user string, ts string) (uid.UID, error) {
+
+	if ts == "" {
+		return nil, fmt.Errorf("Missing timestamp")
+	}
+
+	u := &EphemeralMessageUID{
+		channel: channel,
+		user:    user,
+		ts:      ts,
+	}
+
+	return u, nil
+}
+
+// Channel returns the channel the message was posted to.
+func (u *EphemeralMessageUID) Channel() string {
+	return u.channel
+}
+
+// User returns the ID of the user the message is visible to.
+func (u *EphemeralMessageUID) User() string {
+	return u.user
+}
+
+// Timestamp returns the Slack timestamp ("message_ts") of the message.
+func (u *EphemeralMessageUID) Timestamp() string {
+	return u.ts
+}
+
+// Value returns the string representation of 'u'.
+func (u *EphemeralMessageUID) Value() any {
+	return u.String()
+}
+
+// String returns 'u' in the form of "ephemeral:{CHANNEL}/{USER_ID}/{TIMESTAMP}".
+func (u *EphemeralMessageUID) String() string {
+	return fmt.Sprintf("ephemeral:%s/%s/%s", u.channel, u.user, u.ts)
+}
+
+// ephemeralPayloads updates 'payloads' to be posted as ephemeral messages visible only to 'user' using the
+// chat.postEphemeral API method.
+func ephemeralPayloads(payloads []*payload, user string) error {
+
+	for _, p := range payloads {
+
+		if p.file != nil {
+			return fmt.Errorf("Ephemeral messages can not include files or images")
+		}
+
+		p.method = "chat.postEphemeral"
+		p.args.Set("user", user)
+
+		// Ephemeral messages can not be replied to
+		p.thread = false
+	}
+
+	return nil
+}
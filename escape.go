@@ -0,0 +1,162 @@
+package slack
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kinds of Slack "special" mentions which may be allowed to pass through a `MentionPolicy` unescaped.
+const (
+	// MENTION_USERS matches user mentions, for example `<@U123456>`.
+	MENTION_USERS string = "users"
+	// MENTION_GROUPS matches user group mentions, for example `<!subteam^S123456>`.
+	MENTION_GROUPS string = "groups"
+	// MENTION_CHANNELS matches channel references, for example `<#C123456>`.
+	MENTION_CHANNELS string = "channels"
+	// MENTION_HERE matches `<!here>` and `@here`.
+	MENTION_HERE string = "here"
+	// MENTION_CHANNEL matches `<!channel>` and `@channel`.
+	MENTION_CHANNEL string = "channel"
+	// MENTION_EVERYONE matches `<!everyone>` and `@everyone`.
+	MENTION_EVERYONE string = "everyone"
+)
+
+// word_joiner is inserted between the "@" and the keyword of bare `@here`, `@channel` and `@everyone`
+// strings so that Slack will not treat them as mentions if `link_names` is enabled.
+const word_joiner string = "\u2060"
+
+var re_token = regexp.MustCompile(`<([^<>]*)>`)
+
+var re_bare_special = regexp.MustCompile(`(?i)@(here|channel|everyone)\b`)
+
+var re_user_token = regexp.MustCompile(`^@[UW][A-Z0-9]+(?:\|[^|]*)?$`)
+var re_group_token = regexp.MustCompile(`^!subteam\^[A-Z0-9]+(?:\|[^|]*)?$`)
+var re_channel_token = regexp.MustCompile(`^#[CG][A-Z0-9]+(?:\|[^|]*)?$`)
+var re_special_token = regexp.MustCompile(`^!(here|channel|everyone)(?:\|[^|]*)?$`)
+
+var text_escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// MentionPolicy defines which Slack "special" mentions are allowed to pass through unescaped when
+// message text is sent to Slack. Everything else, including stray `&`, `<` and `>` characters, is escaped
+// so that user-supplied text can not page a channel or break formatting.
+type MentionPolicy struct {
+	allowed map[string]bool
+}
+
+// NewMentionPolicy returns a new `MentionPolicy` allowing zero or more 'kinds' of mentions. Valid kinds
+// are: users, groups, channels, here, channel, everyone. A policy with no kinds escapes all mentions.
+func NewMentionPolicy(kinds ...string) (*MentionPolicy, error) {
+
+	allowed := make(map[string]bool)
+
+	for _, k := range kinds {
+
+		k = strings.TrimSpace(strings.ToLower(k))
+
+		if k == "" {
+			continue
+		}
+
+		switch k {
+		case MENTION_USERS, MENTION_GROUPS, MENTION_CHANNELS, MENTION_HERE, MENTION_CHANNEL, MENTION_EVERYONE:
+			allowed[k] = true
+		default:
+			return nil, fmt.Errorf("Invalid mention kind '%s'", k)
+		}
+	}
+
+	p := &MentionPolicy{
+		allowed: allowed,
+	}
+
+	return p, nil
+}
+
+// NewMentionPolicyFromString returns a new `MentionPolicy` derived from a comma-separated list of
+// mention kinds, for example "users,groups".
+func NewMentionPolicyFromString(str string) (*MentionPolicy, error) {
+	return NewMentionPolicy(strings.Split(str, ",")...)
+}
+
+// Allows reports whether mentions of type 'kind' are allowed by 'p'.
+func (p *MentionPolicy) Allows(kind string) bool {
+	return p.allowed[kind]
+}
+
+// String returns a comma-separated list of the mention kinds allowed by 'p'.
+func (p *MentionPolicy) String() string {
+
+	kinds := make([]string, 0)
+
+	for k := range p.allowed {
+		kinds = append(kinds, k)
+	}
+
+	sort.Strings(kinds)
+	return strings.Join(kinds, ",")
+}
+
+// Escape returns a copy of 'text' with Slack control characters escaped and any mentions not allowed
+// by 'p' neutralized.
+func (p *MentionPolicy) Escape(text string) string {
+
+	var sb strings.Builder
+
+	last := 0
+
+	for _, m := range re_token.FindAllStringSubmatchIndex(text, -1) {
+
+		sb.WriteString(p.escapePlain(text[last:m[0]]))
+
+		token := text[m[2]:m[3]]
+
+		if p.allowsToken(token) {
+			sb.WriteString(text[m[0]:m[1]])
+		} else {
+			sb.WriteString(p.escapePlain(text[m[0]:m[1]]))
+		}
+
+		last = m[1]
+	}
+
+	sb.WriteString(p.escapePlain(text[last:]))
+	return sb.String()
+}
+
+func (p *MentionPolicy) escapePlain(text string) string {
+
+	text = text_escaper.Replace(text)
+
+	return re_bare_special.ReplaceAllStringFunc(text, func(m string) string {
+
+		kind := strings.ToLower(m[1:])
+
+		if p.Allows(kind) {
+			return m
+		}
+
+		return "@" + word_joiner + m[1:]
+	})
+}
+
+func (p *MentionPolicy) allowsToken(token string) bool {
+
+	switch {
+	case re_user_token.MatchString(token):
+		return p.Allows(MENTION_USERS)
+	case re_group_token.MatchString(token):
+		return p.Allows(MENTION_GROUPS)
+	case re_channel_token.MatchString(token):
+		return p.Allows(MENTION_CHANNELS)
+	}
+
+	m := re_special_token.FindStringSubmatch(token)
+
+	if m != nil {
+		return p.Allows(m[1])
+	}
+
+	return false
+}
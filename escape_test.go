@@ -0,0 +1,175 @@
+package slack
+
+import (
+	"testing"
+)
+
+func TestMentionPolicyEscape(t *testing.T) {
+
+	tests := map[string]struct {
+		allow    string
+		text     string
+		expected string
+	}{
+		"control characters": {
+			text:     "a & b < c > d",
+			expected: "a &amp; b &lt; c &gt; d",
+		},
+		"tokens escaped by default": {
+			text:     "<@U0123456789> <!subteam^S0123456789> <#C0123456789> <!here>",
+			expected: "&lt;@U0123456789&gt; &lt;!subteam^S0123456789&gt; &lt;#C0123456789&gt; &lt;!here&gt;",
+		},
+		"bare specials neutralized by default": {
+			text:     "@here @Channel @everyone",
+			expected: "@" + word_joiner + "here @" + word_joiner + "Channel @" + word_joiner + "everyone",
+		},
+		"users": {
+			allow:    "users",
+			text:     "<@U0123456789|bob> <!subteam^S0123456789>",
+			expected: "<@U0123456789|bob> &lt;!subteam^S0123456789&gt;",
+		},
+		"groups": {
+			allow:    "groups",
+			text:     "<@U0123456789> <!subteam^S0123456789>",
+			expected: "&lt;@U0123456789&gt; <!subteam^S0123456789>",
+		},
+		"channels": {
+			allow:    "channels",
+			text:     "<#C0123456789|general> <!channel>",
+			expected: "<#C0123456789|general> &lt;!channel&gt;",
+		},
+		"here": {
+			allow:    "here",
+			text:     "<!here> @here @channel",
+			expected: "<!here> @here @" + word_joiner + "channel",
+		},
+		"handles are left as-is": {
+			text:     "@bob #general",
+			expected: "@bob #general",
+		},
+	}
+
+	for name, tc := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			policy, err := NewMentionPolicyFromString(tc.allow)
+
+			if err != nil {
+				t.Fatalf("Failed to create mention policy, %v", err)
+			}
+
+			got := policy.Escape(tc.text)
+
+			if got != tc.expected {
+				t.Fatalf("Expected %q but got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewMentionPolicyInvalid(t *testing.T) {
+
+	_, err := NewMentionPolicyFromString("users,nobody")
+
+	if err == nil {
+		t.Fatalf("Expected invalid mention kind to fail")
+	}
+}
+
+func TestPostOptionsCheckMentions(t *testing.T) {
+
+	enabled := true
+	disabled := false
+
+	tests := map[string]struct {
+		allow string
+		opts  *PostOptions
+		ok    bool
+	}{
+		"no options": {
+			opts: &PostOptions{},
+			ok:   true,
+		},
+		"link_names": {
+			opts: &PostOptions{LinkNames: &enabled},
+			ok:   false,
+		},
+		"link_names disabled": {
+			opts: &PostOptions{LinkNames: &disabled},
+			ok:   true,
+		},
+		"link_names with users": {
+			allow: "users",
+			opts:  &PostOptions{LinkNames: &enabled},
+			ok:    false,
+		},
+		"link_names with groups": {
+			allow: "groups",
+			opts:  &PostOptions{LinkNames: &enabled},
+			ok:    false,
+		},
+		"link_names with users and groups": {
+			allow: "users,groups",
+			opts:  &PostOptions{LinkNames: &enabled},
+			ok:    true,
+		},
+		"parse full": {
+			allow: "here,channel,everyone",
+			opts:  &PostOptions{Parse: PARSE_FULL},
+			ok:    false,
+		},
+		"parse none": {
+			opts: &PostOptions{Parse: PARSE_NONE},
+			ok:   true,
+		},
+		"parse full with users and groups": {
+			allow: "users,groups",
+			opts:  &PostOptions{Parse: PARSE_FULL},
+			ok:    true,
+		},
+	}
+
+	for name, tc := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			policy, err := NewMentionPolicyFromString(tc.allow)
+
+			if err != nil {
+				t.Fatalf("Failed to create mention policy, %v", err)
+			}
+
+			err = tc.opts.checkMentions(policy)
+
+			if tc.ok && err != nil {
+				t.Fatalf("Expected options to be allowed, %v", err)
+			}
+
+			if !tc.ok && err == nil {
+				t.Fatalf("Expected options to be rejected")
+			}
+		})
+	}
+}
+
+func TestOptionsLinkNamesPolicy(t *testing.T) {
+
+	enabled := true
+
+	opts := DefaultOptions("C0123456789", "xoxb-test")
+
+	err := opts.validate()
+
+	if err != nil {
+		t.Fatalf("Failed to validate default options, %v", err)
+	}
+
+	opts.PostOptions = &PostOptions{LinkNames: &enabled}
+
+	err = opts.validate()
+
+	if err == nil {
+		t.Fatalf("Expected link_names to be rejected by the default mention policy")
+	}
+}
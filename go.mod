@@ -1,19 +1,23 @@
 module github.com/aaronland/go-broadcaster-slack
 
-go 1.19
+go 1.21
 
 require (
 	github.com/aaronland/go-broadcaster v0.0.7
 	github.com/aaronland/go-image-encode v0.0.0-20200215191655-047f61aedbfe
+	github.com/aaronland/go-roster v1.0.0
 	github.com/aaronland/go-uid v0.4.0
+	github.com/sfomuseum/go-flags v0.10.0
 	github.com/sfomuseum/runtimevar v1.0.2
 	github.com/tidwall/gjson v1.14.3
 	github.com/whosonfirst/go-ioutil v1.0.2
+	go.opencensus.io v0.23.0
+	gocloud.dev v0.26.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/aaronland/go-aws-session v0.0.6 // indirect
-	github.com/aaronland/go-roster v1.0.0 // indirect
 	github.com/aaronland/go-string v1.0.0 // indirect
 	github.com/aws/aws-sdk-go v1.43.31 // indirect
 	github.com/aws/aws-sdk-go-v2 v1.16.2 // indirect
@@ -36,11 +40,8 @@ require (
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
-	github.com/sfomuseum/go-flags v0.10.0 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
-	go.opencensus.io v0.23.0 // indirect
-	gocloud.dev v0.26.0 // indirect
 	golang.org/x/net v0.0.0-20220401154927-543a649e0bdd // indirect
 	golang.org/x/sys v0.0.0-20220330033206-e17cdc41300f // indirect
 	golang.org/x/text v0.3.7 // indirect
@@ -0,0 +1,235 @@
+package slack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aaronland/go-uid"
+	"time"
+)
+
+// The default amount of time during which a message with the same idempotency key is considered a duplicate.
+const default_idempotency_window time.Duration = time.Hour
+
+// The maximum amount of time that an idempotency key is reserved for while its message is being broadcast. This limits
+// how long duplicates are blocked for if a process is killed before it can record, or release, the key.
+const idempotency_pending_ttl time.Duration = 10 * time.Minute
+
+// ErrIdempotencyKeyPending is returned when a message with the same idempotency key is still being broadcast.
+var ErrIdempotencyKeyPending = errors.New("Message with the same idempotency key is being broadcast")
+
+// DuplicateUID implements the `uid.UID` interface for a message which was not posted because a message with
+// the same idempotency key had already been broadcast. Its string value is that of the original message's UID.
+type DuplicateUID struct {
+	uid.UID
+	key string
+	id  string
+	ref string
+}
+
+// idempotencyRecord is the value stored in a `KeyStore` for a message which has been broadcast.
+type idempotencyRecord struct {
+	// The string value of the message's UID.
+	UID string `json:"uid"`
+	// The "{CHANNEL_ID}/{TIMESTAMP}" reference of the (first part of the) message, if it can be referenced.
+	Ref string `json:"ref,omitempty"`
+	// Signals that the key has been reserved but the message has not been broadcast yet.
+	Pending bool `json:"pending,omitempty"`
+}
+
+// Key returns the idempotency key of the duplicate message.
+func (u *DuplicateUID) Key() string {
+	return u.key
+}
+
+// Value returns the string representation of 'u'.
+func (u *DuplicateUID) Value() any {
+	return u.String()
+}
+
+// String returns the string value of the UID of the original message.
+func (u *DuplicateUID) String() string {
+	return u.id
+}
+
+// idempotencyKey returns the key used to identify duplicates of 'msg', either the key stored in 'ctx' or a hash
+// of the title, body and encoded images of 'msg' and any per-message options, like an ephemeral user or metadata,
+// stored in 'ctx' or 'msg'. Keys are scoped to the broadcaster's channel.
+func (br *SlackBroadcaster) idempotencyKey(ctx context.Context, msg *Message) (string, error) {
+
+	key, ok := idempotencyKeyFromContext(ctx)
+
+	if !ok {
+
+		h := sha256.New()
+
+		// Fields are separated by a NUL byte so that moving text from the title to the body changes the hash
+
+		h.Write([]byte(msg.Title))
+		h.Write([]byte{0})
+		h.Write([]byte(msg.Body))
+
+		for idx, im := range msg.Images {
+
+			body, err := br.encodeImage(ctx, im)
+
+			if err != nil {
+				return "", fmt.Errorf("Failed to encode image %d, %w", idx, err)
+			}
+
+			h.Write([]byte{0})
+			h.Write(body)
+		}
+
+		// The same text sent to a different user, at a different time or with different metadata is a different message
+
+		opts := newOutboxMessageOptions(ctx)
+
+		if msg.Metadata != nil {
+
+			if opts == nil {
+				opts = &OutboxMessageOptions{}
+			}
+
+			opts.Metadata = msg.Metadata
+		}
+
+		if opts != nil {
+
+			enc_opts, err := json.Marshal(opts)
+
+			if err != nil {
+				return "", fmt.Errorf("Failed to encode message options, %w", err)
+			}
+
+			h.Write([]byte{0})
+			h.Write(enc_opts)
+		}
+
+		key = "sha256:" + hex.EncodeToString(h.Sum(nil))
+	}
+
+	return fmt.Sprintf("%s#%s", br.channel, key), nil
+}
+
+// reserve atomically reserves 'key' while its message is broadcast. If 'key' has already been recorded it returns a
+// `DuplicateUID` for the message previously broadcast with 'key' and false. If 'key' is reserved by another broadcast
+// still in progress it returns an error wrapping `ErrIdempotencyKeyPending`.
+func (br *SlackBroadcaster) reserve(ctx context.Context, key string) (uid.UID, bool, error) {
+
+	rec := &idempotencyRecord{
+		Pending: true,
+	}
+
+	enc, err := json.Marshal(rec)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to encode idempotency record, %w", err)
+	}
+
+	ttl := idempotency_pending_ttl
+
+	if br.idempotency_window < ttl {
+		ttl = br.idempotency_window
+	}
+
+	ok, err := br.idempotency.SetIfAbsent(ctx, key, string(enc), ttl)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to reserve idempotency key, %w", err)
+	}
+
+	if ok {
+		return nil, true, nil
+	}
+
+	dupe_id, ok, err := br.duplicate(ctx, key)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !ok {
+		return nil, false, fmt.Errorf("Failed to reserve idempotency key, key expired while it was being reserved")
+	}
+
+	return dupe_id, false, nil
+}
+
+// release removes the reservation for 'key' so that the message can be broadcast again, for example after it failed to be posted.
+func (br *SlackBroadcaster) release(ctx context.Context, key string) error {
+
+	err := br.idempotency.Delete(ctx, key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to release idempotency key, %w", err)
+	}
+
+	return nil
+}
+
+// duplicate returns a `DuplicateUID` for the message previously broadcast with 'key', if it exists. If 'key' is
+// reserved by a broadcast still in progress it returns an error wrapping `ErrIdempotencyKeyPending`.
+func (br *SlackBroadcaster) duplicate(ctx context.Context, key string) (uid.UID, bool, error) {
+
+	v, ok, err := br.idempotency.Get(ctx, key)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to retrieve idempotency key, %w", err)
+	}
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	var rec *idempotencyRecord
+
+	err = json.Unmarshal([]byte(v), &rec)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("Failed to decode idempotency record, %w", err)
+	}
+
+	if rec.Pending {
+		return nil, false, fmt.Errorf("Failed to broadcast message with idempotency key %s, %w", key, ErrIdempotencyKeyPending)
+	}
+
+	u := &DuplicateUID{
+		key: key,
+		id:  rec.UID,
+		ref: rec.Ref,
+	}
+
+	return u, true, nil
+}
+
+// recordBroadcast stores the UID of the message broadcast with 'key' for the duration of the broadcaster's idempotency window.
+func (br *SlackBroadcaster) recordBroadcast(ctx context.Context, key string, id uid.UID) error {
+
+	rec := &idempotencyRecord{
+		UID: id.String(),
+	}
+
+	channel, ts, err := messageRef(ctx, id)
+
+	if err == nil {
+		rec.Ref = fmt.Sprintf("%s/%s", channel, ts)
+	}
+
+	enc, err := json.Marshal(rec)
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode idempotency record, %w", err)
+	}
+
+	err = br.idempotency.Set(ctx, key, string(enc), br.idempotency_window)
+
+	if err != nil {
+		return fmt.Errorf("Failed to store idempotency key, %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package slack
+
+import (
+	"context"
+	"github.com/aaronland/go-broadcaster"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+
+	br := &SlackBroadcaster{
+		channel: "C0123456789",
+	}
+
+	newMessage := func() *Message {
+		return &Message{
+			Message: &broadcaster.Message{
+				Title: "Deploy finished",
+				Body:  "Version 1.2.3 is live.",
+			},
+		}
+	}
+
+	base, err := br.idempotencyKey(context.Background(), newMessage())
+
+	if err != nil {
+		t.Fatalf("Failed to derive idempotency key, %v", err)
+	}
+
+	post_at := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	parse_none := &PostOptions{Parse: PARSE_NONE}
+
+	tests := map[string]struct {
+		ctx  func(context.Context) context.Context
+		msg  func(*Message)
+		same bool
+	}{
+		"identical": {
+			same: true,
+		},
+		"different body": {
+			msg: func(m *Message) {
+				m.Body = "Version 1.2.4 is live."
+			},
+		},
+		"title moved to body": {
+			msg: func(m *Message) {
+				m.Title = ""
+				m.Body = "Deploy finished" + "Version 1.2.3 is live."
+			},
+		},
+		"ephemeral user": {
+			ctx: func(ctx context.Context) context.Context {
+				return WithEphemeralUser(ctx, "U0123456789")
+			},
+		},
+		"post at": {
+			ctx: func(ctx context.Context) context.Context {
+				return WithPostAt(ctx, post_at)
+			},
+		},
+		"identity": {
+			ctx: func(ctx context.Context) context.Context {
+				return WithIdentity(ctx, &Identity{Username: "deploy-bot"})
+			},
+		},
+		"post options": {
+			ctx: func(ctx context.Context) context.Context {
+				return WithPostOptions(ctx, parse_none)
+			},
+		},
+		"context metadata": {
+			ctx: func(ctx context.Context) context.Context {
+				return WithMetadata(ctx, &Metadata{EventType: "deploy_finished"})
+			},
+		},
+		"message metadata": {
+			msg: func(m *Message) {
+				m.Metadata = &Metadata{EventType: "deploy_finished"}
+			},
+		},
+	}
+
+	for name, tc := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			ctx := context.Background()
+
+			if tc.ctx != nil {
+				ctx = tc.ctx(ctx)
+			}
+
+			msg := newMessage()
+
+			if tc.msg != nil {
+				tc.msg(msg)
+			}
+
+			key, err := br.idempotencyKey(ctx, msg)
+
+			if err != nil {
+				t.Fatalf("Failed to derive idempotency key, %v", err)
+			}
+
+			if tc.same && key != base {
+				t.Fatalf("Expected key %s but got %s", base, key)
+			}
+
+			if !tc.same && key == base {
+				t.Fatalf("Expected key to differ from %s", base)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyFromContext(t *testing.T) {
+
+	br := &SlackBroadcaster{
+		channel: "C0123456789",
+	}
+
+	msg := &Message{
+		Message: &broadcaster.Message{
+			Body: "Version 1.2.3 is live.",
+		},
+	}
+
+	// An explicit key takes precedence over any per-message options
+
+	ctx := WithIdempotencyKey(context.Background(), "deploy-1.2.3")
+	ctx = WithEphemeralUser(ctx, "U0123456789")
+
+	key, err := br.idempotencyKey(ctx, msg)
+
+	if err != nil {
+		t.Fatalf("Failed to derive idempotency key, %v", err)
+	}
+
+	expected := "C0123456789#deploy-1.2.3"
+
+	if key != expected {
+		t.Fatalf("Expected key %s but got %s", expected, key)
+	}
+}
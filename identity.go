@@ -0,0 +1,160 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The OAuth scope required to customize the username and icon of messages.
+const SCOPE_CHAT_WRITE_CUSTOMIZE string = "chat:write.customize"
+
+// Identity defines a custom username and icon to post messages as. This requires that the Slack
+// API token has the `chat:write.customize` scope.
+type Identity struct {
+	// Username is the name to display as the author of a message.
+	Username string `json:"username,omitempty"`
+	// IconEmoji is the emoji, for example ":robot_face:", to display as the icon of a message.
+	IconEmoji string `json:"icon_emoji,omitempty"`
+	// IconURL is the URL of an image to display as the icon of a message.
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+// IsZero reports whether 'id' does not define any custom properties.
+func (id *Identity) IsZero() bool {
+	return id == nil || (id.Username == "" && id.IconEmoji == "" && id.IconURL == "")
+}
+
+// Validate ensures that the properties of 'id' are valid.
+func (id *Identity) Validate() error {
+
+	if id.IconEmoji != "" && id.IconURL != "" {
+		return fmt.Errorf("Only one of icon emoji or icon URL may be specified")
+	}
+
+	if id.IconURL != "" {
+
+		u, err := url.Parse(id.IconURL)
+
+		if err != nil {
+			return fmt.Errorf("Failed to parse icon URL, %w", err)
+		}
+
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Invalid icon URL '%s'", id.IconURL)
+		}
+	}
+
+	return nil
+}
+
+// merge returns a new `Identity` whose properties are those of 'id' overridden by any non-empty
+// properties of 'other'. If 'other' defines an icon it replaces both of the icon properties of 'id'.
+func (id *Identity) merge(other *Identity) *Identity {
+
+	merged := &Identity{}
+
+	if id != nil {
+		*merged = *id
+	}
+
+	if other == nil {
+		return merged
+	}
+
+	if other.Username != "" {
+		merged.Username = other.Username
+	}
+
+	if other.IconEmoji != "" || other.IconURL != "" {
+		merged.IconEmoji = other.IconEmoji
+		merged.IconURL = other.IconURL
+	}
+
+	return merged
+}
+
+// apply assigns the properties of 'id' to 'args'.
+func (id *Identity) apply(args url.Values) {
+
+	if id.Username != "" {
+		args.Set("username", id.Username)
+	}
+
+	if id.IconEmoji != "" {
+		args.Set("icon_emoji", normalizeEmoji(id.IconEmoji))
+	}
+
+	if id.IconURL != "" {
+		args.Set("icon_url", id.IconURL)
+	}
+}
+
+// normalizeEmoji ensures that 'name' is wrapped in colons, for example ":robot_face:".
+func normalizeEmoji(name string) string {
+	return fmt.Sprintf(":%s:", strings.Trim(name, ":"))
+}
+
+// scopes returns the list of OAuth scopes granted to the token associated with 'br', derived from the
+// "X-OAuth-Scopes" header of an auth.test API response.
+func (br *SlackBroadcaster) scopes(ctx context.Context) ([]string, error) {
+
+	req, err := http.NewRequest("POST", SLACK_API_ENDPOINT+"auth.test", nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create new request, %w", err)
+	}
+
+	rsp, body, err := br.exchange(ctx, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = readAPIResponse("auth.test", bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]string, 0)
+
+	for _, s := range strings.Split(rsp.Header.Get("X-OAuth-Scopes"), ",") {
+
+		s = strings.TrimSpace(s)
+
+		if s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes, nil
+}
+
+// checkIdentityScope logs a warning if the token associated with 'br' does not have the scope required
+// to post messages with a custom identity.
+func (br *SlackBroadcaster) checkIdentityScope(ctx context.Context) {
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	scopes, err := br.scopes(ctx)
+
+	if err != nil {
+		br.logf("Unable to determine OAuth scopes for token, %v\n", err)
+		return
+	}
+
+	for _, s := range scopes {
+
+		if s == SCOPE_CHAT_WRITE_CUSTOMIZE {
+			return
+		}
+	}
+
+	br.logf("Token is missing the %s scope required to post messages with a custom username or icon\n", SCOPE_CHAT_WRITE_CUSTOMIZE)
+}
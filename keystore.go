@@ -0,0 +1,109 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-roster"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeyStore is an interface for storing values, such as the IDs of messages which have already been broadcast,
+// for a limited amount of time.
+type KeyStore interface {
+	// Get returns the value stored for a key and a boolean flag signaling whether the key exists and has not expired.
+	Get(context.Context, string) (string, bool, error)
+	// Set stores a value for a key for a given amount of time.
+	Set(context.Context, string, string, time.Duration) error
+	// SetIfAbsent atomically stores a value for a key for a given amount of time, unless the key already exists and
+	// has not expired, and returns a boolean flag signaling whether the value was stored.
+	SetIfAbsent(context.Context, string, string, time.Duration) (bool, error)
+	// Delete removes a key. Removing a key which does not exist is not an error.
+	Delete(context.Context, string) error
+}
+
+var keystore_roster roster.Roster
+
+// KeyStoreInitializationFunc is a function defined by individual key store package and used to create
+// an instance of that key store
+type KeyStoreInitializationFunc func(ctx context.Context, uri string) (KeyStore, error)
+
+// RegisterKeyStore registers 'scheme' as a key pointing to 'init_func' in an internal lookup table
+// used to create new `KeyStore` instances by the `NewKeyStore` method.
+func RegisterKeyStore(ctx context.Context, scheme string, init_func KeyStoreInitializationFunc) error {
+
+	err := ensureKeyStoreRoster()
+
+	if err != nil {
+		return err
+	}
+
+	return keystore_roster.Register(ctx, scheme, init_func)
+}
+
+func ensureKeyStoreRoster() error {
+
+	if keystore_roster == nil {
+
+		r, err := roster.NewDefaultRoster()
+
+		if err != nil {
+			return err
+		}
+
+		keystore_roster = r
+	}
+
+	return nil
+}
+
+// NewKeyStore returns a new `KeyStore` instance configured by 'uri'. The value of 'uri' is parsed
+// as a `url.URL` and its scheme is used as the key for a corresponding `KeyStoreInitializationFunc`
+// function used to instantiate the new `KeyStore`. It is assumed that the scheme (and initialization
+// function) have been registered by the `RegisterKeyStore` method.
+func NewKeyStore(ctx context.Context, uri string) (KeyStore, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = ensureKeyStoreRoster()
+
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := keystore_roster.Driver(ctx, u.Scheme)
+
+	if err != nil {
+		return nil, err
+	}
+
+	init_func := i.(KeyStoreInitializationFunc)
+	return init_func(ctx, uri)
+}
+
+// KeyStoreSchemes returns the list of key store schemes that have been registered.
+func KeyStoreSchemes() []string {
+
+	ctx := context.Background()
+	schemes := []string{}
+
+	err := ensureKeyStoreRoster()
+
+	if err != nil {
+		return schemes
+	}
+
+	for _, dr := range keystore_roster.Drivers(ctx) {
+		scheme := fmt.Sprintf("%s://", strings.ToLower(dr))
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+	return schemes
+}
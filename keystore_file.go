@@ -0,0 +1,231 @@
+package slack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+func init() {
+	ctx := context.Background()
+	RegisterKeyStore(ctx, "file", NewFileKeyStore)
+}
+
+type fileKeyStoreItem struct {
+	Value   string    `json:"value"`
+	Expires time.Time `json:"expires"`
+}
+
+// FileKeyStore implements the `KeyStore` interface for values stored as files in a local directory so that
+// they persist across process restarts.
+type FileKeyStore struct {
+	KeyStore
+	root string
+	mu   *sync.Mutex
+}
+
+// NewFileKeyStore returns a new `FileKeyStore` instance configured by 'uri' which is expected to take
+// the form of:
+//
+//	file:///path/to/directory
+//
+// The directory is created if it does not exist.
+func NewFileKeyStore(ctx context.Context, uri string) (KeyStore, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	if u.Path == "" {
+		return nil, fmt.Errorf("Missing key store directory")
+	}
+
+	err = os.MkdirAll(u.Path, 0700)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create key store directory, %w", err)
+	}
+
+	s := &FileKeyStore{
+		root: u.Path,
+		mu:   new(sync.Mutex),
+	}
+
+	return s, nil
+}
+
+// Get returns the value stored for 'key' and a boolean flag signaling whether the key exists and has not expired.
+func (s *FileKeyStore) Get(ctx context.Context, key string) (string, bool, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.get(key)
+}
+
+// get returns the value stored for 'key', removing it if it has expired. The caller is expected to hold the store's lock.
+func (s *FileKeyStore) get(key string) (string, bool, error) {
+
+	path := s.path(key)
+
+	body, err := os.ReadFile(path)
+
+	if err != nil {
+
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", false, nil
+		}
+
+		return "", false, fmt.Errorf("Failed to read key, %w", err)
+	}
+
+	var item *fileKeyStoreItem
+
+	err = json.Unmarshal(body, &item)
+
+	if err != nil {
+		return "", false, fmt.Errorf("Failed to decode key, %w", err)
+	}
+
+	if time.Now().After(item.Expires) {
+		os.Remove(path)
+		return "", false, nil
+	}
+
+	return item.Value, true, nil
+}
+
+// Set stores 'value' for 'key' for 'ttl'.
+func (s *FileKeyStore) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp_path, err := s.writeTemp(value, ttl)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp_path, s.path(key))
+
+	if err != nil {
+		os.Remove(tmp_path)
+		return fmt.Errorf("Failed to commit key, %w", err)
+	}
+
+	return nil
+}
+
+// SetIfAbsent stores 'value' for 'key' for 'ttl', unless 'key' already exists and has not expired, and returns
+// a boolean flag signaling whether 'value' was stored. Values are committed using a hard link, which fails if the
+// key's file already exists, so that only one process can store a value for a key that does not exist.
+func (s *FileKeyStore) SetIfAbsent(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// This also removes the key's file if the key has expired
+
+	_, ok, err := s.get(key)
+
+	if err != nil {
+		return false, err
+	}
+
+	if ok {
+		return false, nil
+	}
+
+	tmp_path, err := s.writeTemp(value, ttl)
+
+	if err != nil {
+		return false, err
+	}
+
+	defer os.Remove(tmp_path)
+
+	err = os.Link(tmp_path, s.path(key))
+
+	if err != nil {
+
+		if errors.Is(err, fs.ErrExist) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("Failed to commit key, %w", err)
+	}
+
+	return true, nil
+}
+
+// Delete removes 'key'.
+func (s *FileKeyStore) Delete(ctx context.Context, key string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(key))
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("Failed to remove key, %w", err)
+	}
+
+	return nil
+}
+
+// writeTemp writes 'value', and its expiry time, to a new temporary file in the store's directory and returns its path.
+func (s *FileKeyStore) writeTemp(value string, ttl time.Duration) (string, error) {
+
+	item := &fileKeyStoreItem{
+		Value:   value,
+		Expires: time.Now().Add(ttl),
+	}
+
+	enc, err := json.Marshal(item)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode key, %w", err)
+	}
+
+	wr, err := os.CreateTemp(s.root, ".*.tmp")
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to create key, %w", err)
+	}
+
+	_, err = wr.Write(enc)
+
+	if err != nil {
+		wr.Close()
+		os.Remove(wr.Name())
+		return "", fmt.Errorf("Failed to write key, %w", err)
+	}
+
+	err = wr.Close()
+
+	if err != nil {
+		os.Remove(wr.Name())
+		return "", fmt.Errorf("Failed to write key, %w", err)
+	}
+
+	return wr.Name(), nil
+}
+
+// path returns the path of the file used to store 'key'. Keys are hashed since they may contain
+// characters which are not valid in file names.
+func (s *FileKeyStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.root, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,116 @@
+package slack
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+func init() {
+	ctx := context.Background()
+	RegisterKeyStore(ctx, "memory", NewMemoryKeyStore)
+}
+
+type memoryKeyStoreItem struct {
+	value   string
+	expires time.Time
+}
+
+// MemoryKeyStore implements the `KeyStore` interface for values stored in memory. Values do not
+// persist across process restarts.
+type MemoryKeyStore struct {
+	KeyStore
+	items map[string]*memoryKeyStoreItem
+	mu    *sync.Mutex
+}
+
+// NewMemoryKeyStore returns a new `MemoryKeyStore` instance configured by 'uri' which is expected
+// to take the form of:
+//
+//	memory://
+func NewMemoryKeyStore(ctx context.Context, uri string) (KeyStore, error) {
+
+	s := &MemoryKeyStore{
+		items: make(map[string]*memoryKeyStoreItem),
+		mu:    new(sync.Mutex),
+	}
+
+	return s, nil
+}
+
+// Get returns the value stored for 'key' and a boolean flag signaling whether the key exists and has not expired.
+func (s *MemoryKeyStore) Get(ctx context.Context, key string) (string, bool, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+
+	if !ok {
+		return "", false, nil
+	}
+
+	if time.Now().After(item.expires) {
+		delete(s.items, key)
+		return "", false, nil
+	}
+
+	return item.value, true, nil
+}
+
+// Set stores 'value' for 'key' for 'ttl'.
+func (s *MemoryKeyStore) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value, ttl)
+	return nil
+}
+
+// SetIfAbsent stores 'value' for 'key' for 'ttl', unless 'key' already exists and has not expired, and returns
+// a boolean flag signaling whether 'value' was stored.
+func (s *MemoryKeyStore) SetIfAbsent(ctx context.Context, key string, value string, ttl time.Duration) (bool, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+
+	if ok && !time.Now().After(item.expires) {
+		return false, nil
+	}
+
+	s.set(key, value, ttl)
+	return true, nil
+}
+
+// Delete removes 'key'.
+func (s *MemoryKeyStore) Delete(ctx context.Context, key string) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, key)
+	return nil
+}
+
+// set stores 'value' for 'key' for 'ttl'. The caller is expected to hold the store's lock.
+func (s *MemoryKeyStore) set(key string, value string, ttl time.Duration) {
+
+	now := time.Now()
+
+	// Prune expired items so that long-running processes don't accumulate keys indefinitely
+
+	for k, item := range s.items {
+
+		if now.After(item.expires) {
+			delete(s.items, k)
+		}
+	}
+
+	s.items[key] = &memoryKeyStoreItem{
+		value:   value,
+		expires: now.Add(ttl),
+	}
+}
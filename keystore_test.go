@@ -0,0 +1,116 @@
+package slack
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeyStoreSetIfAbsent(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := map[string]func(t *testing.T) string{
+		"memory": func(t *testing.T) string {
+			return "memory://"
+		},
+		"file": func(t *testing.T) string {
+			return "file://" + t.TempDir()
+		},
+	}
+
+	for name, uri := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			s, err := NewKeyStore(ctx, uri(t))
+
+			if err != nil {
+				t.Fatalf("Failed to create key store, %v", err)
+			}
+
+			// Only one of several concurrent callers may store a value for the same key
+
+			var stored int32
+
+			wg := new(sync.WaitGroup)
+
+			for i := 0; i < 8; i++ {
+
+				wg.Add(1)
+
+				go func() {
+
+					defer wg.Done()
+
+					ok, err := s.SetIfAbsent(ctx, "C0123456789#key", "value", time.Minute)
+
+					if err != nil {
+						t.Errorf("Failed to set key, %v", err)
+						return
+					}
+
+					if ok {
+						atomic.AddInt32(&stored, 1)
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			if stored != 1 {
+				t.Fatalf("Expected key to be stored once but it was stored %d times", stored)
+			}
+
+			v, ok, err := s.Get(ctx, "C0123456789#key")
+
+			if err != nil {
+				t.Fatalf("Failed to get key, %v", err)
+			}
+
+			if !ok || v != "value" {
+				t.Fatalf("Expected stored value but got '%s' (%t)", v, ok)
+			}
+
+			// Deleting a key allows it to be stored again
+
+			err = s.Delete(ctx, "C0123456789#key")
+
+			if err != nil {
+				t.Fatalf("Failed to delete key, %v", err)
+			}
+
+			ok, err = s.SetIfAbsent(ctx, "C0123456789#key", "other", time.Minute)
+
+			if err != nil {
+				t.Fatalf("Failed to set key, %v", err)
+			}
+
+			if !ok {
+				t.Fatalf("Expected deleted key to be stored again")
+			}
+
+			// Expired keys are treated as absent
+
+			_, err = s.SetIfAbsent(ctx, "expired", "value", time.Nanosecond)
+
+			if err != nil {
+				t.Fatalf("Failed to set key, %v", err)
+			}
+
+			time.Sleep(time.Millisecond)
+
+			ok, err = s.SetIfAbsent(ctx, "expired", "value", time.Minute)
+
+			if err != nil {
+				t.Fatalf("Failed to set key, %v", err)
+			}
+
+			if !ok {
+				t.Fatalf("Expected expired key to be stored again")
+			}
+		})
+	}
+}
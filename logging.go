@@ -0,0 +1,358 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"go.opencensus.io/trace"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Matches Slack API tokens (bot, user, app, refresh and configuration tokens) so they can be redacted from logs.
+var re_api_token = regexp.MustCompile(`\bxox[a-z]-[A-Za-z0-9-]+|\bxapp-[A-Za-z0-9-]+|\bxoxe(?:\.xox[a-z])?-[A-Za-z0-9-]+`)
+
+// The string used to replace redacted values in logs.
+const redacted string = "[REDACTED]"
+
+// The maximum number of bytes of a request or response body to include in debug logs.
+const max_debug_body int = 4096
+
+// apiCall describes a single HTTP request to the Slack API, for logging.
+type apiCall struct {
+	method         string
+	attempt        int
+	status         int
+	code           string
+	latency        time.Duration
+	wait           time.Duration
+	request_bytes  int64
+	response_bytes int
+	err            error
+}
+
+// countingReadCloser wraps an `io.ReadCloser` and counts the number of bytes read from it.
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// newStructuredLogger returns a new `slog.Logger` which writes text records to the same destination as 'logger'.
+func newStructuredLogger(logger *log.Logger, debug bool) *slog.Logger {
+
+	level := slog.LevelInfo
+
+	if debug {
+		level = slog.LevelDebug
+	}
+
+	h := slog.NewTextHandler(logger.Writer(), &slog.HandlerOptions{Level: level})
+	return slog.New(h)
+}
+
+// SetStructuredLogger assigns 'logger' as the `slog.Logger` used to log Slack API calls made by 'br'. Once assigned
+// it is not replaced when the `SetLogger` method is called. It is safe to call while messages are being broadcast.
+func (br *SlackBroadcaster) SetStructuredLogger(ctx context.Context, logger *slog.Logger) error {
+
+	if logger == nil {
+		return fmt.Errorf("Missing logger")
+	}
+
+	br.logger_mu.Lock()
+	defer br.logger_mu.Unlock()
+
+	br.slogger = logger
+	br.custom_slogger = true
+	return nil
+}
+
+// logf writes a message to the logger for 'br', which may be replaced by `SetLogger` at any time.
+func (br *SlackBroadcaster) logf(format string, args ...any) {
+
+	br.logger_mu.Lock()
+	logger := br.logger
+	br.logger_mu.Unlock()
+
+	logger.Printf(format, args...)
+}
+
+// structuredLogger returns the structured logger for 'br', which may be replaced by `SetLogger` or `SetStructuredLogger` at any time.
+func (br *SlackBroadcaster) structuredLogger() *slog.Logger {
+
+	br.logger_mu.Lock()
+	defer br.logger_mu.Unlock()
+
+	return br.slogger
+}
+
+// exchange sends 'req', waiting on the broadcaster's rate limiter first, and returns the response and its body. Requests
+// which are rate limited by Slack are retried up to the broadcaster's maximum number of retries if their body can be
+// replayed. Each attempt is logged, measured and traced as its own span.
+func (br *SlackBroadcaster) exchange(ctx context.Context, req *http.Request) (*http.Response, []byte, error) {
+
+	method := apiMethod(req)
+
+	for attempt := 1; ; attempt++ {
+
+		call := &apiCall{
+			method:  method,
+			attempt: attempt,
+		}
+
+		if attempt > 1 {
+
+			body, err := req.GetBody()
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("Failed to reset request body, %w", err)
+			}
+
+			req.Body = body
+		}
+
+		// Each attempt, including the time spent waiting on the rate limiter, gets its own span
+
+		call_ctx, span := trace.StartSpan(ctx, "slack."+method)
+		span.AddAttributes(trace.StringAttribute(ATTR_CHANNEL, br.channel))
+
+		if br.rate_limiter != nil {
+
+			d, err := br.rate_limiter.Wait(call_ctx, br.token, br.channel, method)
+
+			call.wait = d
+
+			if err != nil {
+				call.err = err
+				br.reportAPICall(call_ctx, span, call)
+				return nil, nil, fmt.Errorf("Failed to wait for rate limiter, %w", err)
+			}
+		}
+
+		var counter *countingReadCloser
+
+		if req.Body != nil {
+			counter = &countingReadCloser{ReadCloser: req.Body}
+			req.Body = counter
+		}
+
+		// The Authorization header is set before the request is logged so that it is redacted rather than omitted
+
+		br.authorize(req)
+		br.logRequest(call_ctx, req, attempt)
+
+		t1 := time.Now()
+
+		rsp, err := br.do(call_ctx, req)
+
+		call.latency = time.Since(t1)
+
+		if counter != nil {
+			call.request_bytes = counter.n
+		}
+
+		if err != nil {
+			call.err = err
+			br.reportAPICall(call_ctx, span, call)
+			return nil, nil, err
+		}
+
+		body, err := io.ReadAll(rsp.Body)
+		rsp.Body.Close()
+
+		call.latency = time.Since(t1)
+		call.status = rsp.StatusCode
+		call.response_bytes = len(body)
+
+		if err != nil {
+			call.err = err
+			br.reportAPICall(call_ctx, span, call)
+			return nil, nil, fmt.Errorf("Failed to read API response, %w", err)
+		}
+
+		if rsp.StatusCode == http.StatusOK && !gjson.GetBytes(body, "ok").Bool() {
+			call.code = gjson.GetBytes(body, "error").String()
+		}
+
+		br.reportAPICall(call_ctx, span, call)
+		br.logResponse(call_ctx, method, rsp, body)
+
+		if rsp.StatusCode != http.StatusTooManyRequests {
+			return rsp, body, nil
+		}
+
+		d := retryAfter(rsp)
+
+		// Make every broadcaster sharing the rate limiter back off, not just this one
+
+		if br.rate_limiter != nil {
+			br.rate_limiter.Block(br.token, method, d)
+		}
+
+		replayable := req.Body == nil || req.GetBody != nil
+
+		if attempt > br.max_retries || !replayable {
+			return rsp, body, fmt.Errorf("API call was rate limited, retry after %v", d)
+		}
+
+		// The rate limiter, if present, has already been told to wait
+
+		if br.rate_limiter == nil {
+
+			timer := time.NewTimer(d)
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, nil, ctx.Err()
+			case <-timer.C:
+				// pass
+			}
+		}
+	}
+}
+
+// reportAPICall logs 'call', records its measurements and ends its trace span.
+func (br *SlackBroadcaster) reportAPICall(ctx context.Context, span *trace.Span, call *apiCall) {
+	br.logAPICall(ctx, call)
+	recordAPICall(ctx, call)
+	traceAPICall(span, call)
+}
+
+// logAPICall logs a summary of 'call' at a level determined by its outcome. Successful calls are logged at the debug
+// level so that API calls are only logged by default if they fail.
+func (br *SlackBroadcaster) logAPICall(ctx context.Context, call *apiCall) {
+
+	level := slog.LevelDebug
+
+	switch {
+	case call.err != nil, call.code != "":
+		level = slog.LevelError
+	case call.status == http.StatusTooManyRequests:
+		level = slog.LevelWarn
+	case call.status != http.StatusOK:
+		level = slog.LevelError
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", call.method),
+		slog.String("channel", br.channel),
+		slog.Int("attempt", call.attempt),
+		slog.Duration("latency", call.latency),
+		slog.Int64("request_bytes", call.request_bytes),
+	}
+
+	if call.status != 0 {
+		attrs = append(attrs, slog.Int("status", call.status), slog.Int("response_bytes", call.response_bytes))
+	}
+
+	if call.code != "" {
+		attrs = append(attrs, slog.String("slack_error", call.code))
+	}
+
+	if call.wait > 0 {
+		attrs = append(attrs, slog.Duration("rate_limit_wait", call.wait))
+	}
+
+	if call.err != nil {
+		attrs = append(attrs, slog.String("error", redactString(call.err.Error())))
+	}
+
+	br.structuredLogger().LogAttrs(ctx, level, "Slack API call", attrs...)
+}
+
+// logRequest logs the headers and body of 'req', with credentials redacted, at the debug level.
+func (br *SlackBroadcaster) logRequest(ctx context.Context, req *http.Request, attempt int) {
+
+	slogger := br.structuredLogger()
+
+	if !slogger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	body := ""
+
+	switch {
+	case req.Body == nil:
+		// pass
+	case req.GetBody == nil:
+		// Streamed bodies, like file uploads, can only be read once
+		body = fmt.Sprintf("(streamed %s body)", req.Header.Get("Content-Type"))
+	default:
+
+		r, err := req.GetBody()
+
+		if err == nil {
+			b, _ := io.ReadAll(io.LimitReader(r, int64(max_debug_body)+1))
+			r.Close()
+			body = truncateDebugBody(b)
+		}
+	}
+
+	slogger.LogAttrs(ctx, slog.LevelDebug, "Slack API request",
+		slog.String("method", apiMethod(req)),
+		slog.Int("attempt", attempt),
+		slog.Any("headers", redactHeaders(req.Header)),
+		slog.String("body", redactString(body)),
+	)
+}
+
+// logResponse logs the headers and body of 'rsp', with credentials redacted, at the debug level.
+func (br *SlackBroadcaster) logResponse(ctx context.Context, method string, rsp *http.Response, body []byte) {
+
+	slogger := br.structuredLogger()
+
+	if !slogger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	slogger.LogAttrs(ctx, slog.LevelDebug, "Slack API response",
+		slog.String("method", method),
+		slog.Int("status", rsp.StatusCode),
+		slog.Any("headers", redactHeaders(rsp.Header)),
+		slog.String("body", redactString(truncateDebugBody(body))),
+	)
+}
+
+// redactHeaders returns a copy of 'h' as a map with the values of credential headers redacted.
+func redactHeaders(h http.Header) map[string]string {
+
+	m := make(map[string]string)
+
+	for k, v := range h {
+
+		switch http.CanonicalHeaderKey(k) {
+		case "Authorization", "Cookie", "Set-Cookie":
+			m[k] = redacted
+		default:
+			m[k] = redactString(strings.Join(v, ", "))
+		}
+	}
+
+	return m
+}
+
+// redactString replaces any Slack API tokens in 's'.
+func redactString(s string) string {
+	return re_api_token.ReplaceAllString(s, redacted)
+}
+
+// truncateDebugBody returns 'b' as a string truncated to `max_debug_body` bytes.
+func truncateDebugBody(b []byte) string {
+
+	if len(b) <= max_debug_body {
+		return string(b)
+	}
+
+	return fmt.Sprintf("%s… (truncated)", b[:max_debug_body])
+}
@@ -0,0 +1,348 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policies for handling @handles and #channel names which can not be resolved.
+const (
+	// UNRESOLVED_KEEP leaves unresolved names in the message text as-is.
+	UNRESOLVED_KEEP string = "keep"
+	// UNRESOLVED_WARN leaves unresolved names in the message text as-is and logs a warning.
+	UNRESOLVED_WARN string = "warn"
+	// UNRESOLVED_ERROR causes the broadcast to fail if any names can not be resolved.
+	UNRESOLVED_ERROR string = "error"
+)
+
+// The default amount of time that lists of users, user groups and channels are cached for.
+const default_mention_cache_ttl time.Duration = 15 * time.Minute
+
+// Matches "@handle", "@user@example.com" and "#channel-name" references which are not part of
+// a word, a URL or an escaped entity.
+var re_mention_ref = regexp.MustCompile(`(^|[^\w&/@#<|])([@#])((?:[\w.+\-]+@[\w\-]+(?:\.[\w\-]+)+)|(?:\w[\w.\-]*))`)
+
+// Matches purely numeric references, for example "#1234", which are assumed to be issue numbers rather than channels.
+var re_numeric = regexp.MustCompile(`^\d+$`)
+
+// Matches code fences and inline code spans, which are never scanned for mentions.
+var re_code = regexp.MustCompile("(?s)```.*?```|`[^`\n]*`")
+
+// mentionCache is a time-limited lookup table mapping lower-cased names to Slack IDs.
+type mentionCache struct {
+	ids     map[string]string
+	expires time.Time
+}
+
+// mentionResolver resolves user handles, email addresses, user group handles and channel names in to
+// Slack IDs using the users.list, usergroups.list and conversations.list API methods.
+type mentionResolver struct {
+	api      func(context.Context, string, url.Values) ([]byte, error)
+	ttl      time.Duration
+	mu       *sync.Mutex
+	users    *mentionCache
+	groups   *mentionCache
+	channels *mentionCache
+}
+
+func newMentionResolver(api func(context.Context, string, url.Values) ([]byte, error), ttl time.Duration) *mentionResolver {
+
+	r := &mentionResolver{
+		api: api,
+		ttl: ttl,
+		mu:  new(sync.Mutex),
+	}
+
+	return r
+}
+
+// Resolve replaces "@handle", "@email" and "#channel" references in 'text' with their corresponding Slack
+// mention tokens, limited to the kinds of mentions allowed by 'policy'. References are only looked up among the
+// kinds of mentions that 'policy' allows. It returns the updated text and the list of references which could not
+// be resolved. 'text' is expected to have already been escaped. If a list of users, user groups or channels can not
+// be retrieved the references which depend on it are left as-is, included in the list of unresolved references and
+// the error is returned alongside the updated text.
+func (r *mentionResolver) Resolve(ctx context.Context, text string, policy *MentionPolicy) (string, []string, error) {
+
+	state := &resolveState{
+		unresolved: make([]string, 0),
+		errors:     make(map[string]error),
+	}
+
+	var sb strings.Builder
+	last := 0
+
+	for _, m := range re_code.FindAllStringIndex(text, -1) {
+		sb.WriteString(r.resolveSegment(ctx, text[last:m[0]], policy, state))
+		sb.WriteString(text[m[0]:m[1]])
+		last = m[1]
+	}
+
+	sb.WriteString(r.resolveSegment(ctx, text[last:], policy, state))
+
+	errs := make([]error, 0)
+
+	for _, kind := range []string{"users", "groups", "channels"} {
+
+		err, ok := state.errors[kind]
+
+		if ok {
+			errs = append(errs, err)
+		}
+	}
+
+	return sb.String(), state.unresolved, errors.Join(errs...)
+}
+
+// resolveState records the references which could not be resolved, and the lists which could not be retrieved,
+// during a single call to `Resolve`. Lists which fail to be retrieved are not requested again for the same call.
+type resolveState struct {
+	unresolved []string
+	errors     map[string]error
+}
+
+func (r *mentionResolver) resolveSegment(ctx context.Context, text string, policy *MentionPolicy, state *resolveState) string {
+
+	var sb strings.Builder
+	last := 0
+
+	for _, m := range re_mention_ref.FindAllStringSubmatchIndex(text, -1) {
+
+		prefix := text[m[2]:m[3]]
+		sigil := text[m[4]:m[5]]
+		name := strings.TrimRight(text[m[6]:m[7]], ".-")
+		name_end := m[6] + len(name)
+
+		sb.WriteString(text[last:m[0]])
+		sb.WriteString(prefix)
+
+		if sigil == "#" && re_numeric.MatchString(name) {
+			sb.WriteString(text[m[4]:name_end])
+			last = name_end
+			continue
+		}
+
+		if !allowsSigil(policy, sigil) {
+			sb.WriteString(text[m[4]:name_end])
+			last = name_end
+			continue
+		}
+
+		token, ok := r.resolveName(ctx, sigil, name, policy, state)
+
+		if ok {
+			sb.WriteString(token)
+		} else {
+			state.unresolved = append(state.unresolved, sigil+name)
+			sb.WriteString(text[m[4]:name_end])
+		}
+
+		last = name_end
+	}
+
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// allowsSigil reports whether 'policy' allows any of the kinds of mentions that references starting with 'sigil' can resolve to.
+func allowsSigil(policy *MentionPolicy, sigil string) bool {
+
+	if sigil == "#" {
+		return policy.Allows(MENTION_CHANNELS)
+	}
+
+	return policy.Allows(MENTION_USERS) || policy.Allows(MENTION_GROUPS)
+}
+
+// resolveName returns the mention token for 'sigil' and 'name', only considering the kinds of mentions allowed
+// by 'policy'. Lists which can not be retrieved are recorded in 'state' and treated as if they did not contain 'name'.
+func (r *mentionResolver) resolveName(ctx context.Context, sigil string, name string, policy *MentionPolicy, state *resolveState) (string, bool) {
+
+	key := strings.ToLower(name)
+
+	kinds := []string{"channels"}
+
+	if sigil == "@" {
+
+		kinds = make([]string, 0)
+
+		if policy.Allows(MENTION_USERS) {
+			kinds = append(kinds, "users")
+		}
+
+		if policy.Allows(MENTION_GROUPS) {
+			kinds = append(kinds, "groups")
+		}
+	}
+
+	for _, kind := range kinds {
+
+		_, failed := state.errors[kind]
+
+		if failed {
+			continue
+		}
+
+		id, ok, err := r.lookup(ctx, kind, key)
+
+		if err != nil {
+			state.errors[kind] = err
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		switch kind {
+		case "users":
+			return fmt.Sprintf("<@%s>", id), true
+		case "groups":
+			return fmt.Sprintf("<!subteam^%s>", id), true
+		default:
+			return fmt.Sprintf("<#%s>", id), true
+		}
+	}
+
+	return "", false
+}
+
+// ChannelID returns the Slack ID for the channel named 'name'.
+func (r *mentionResolver) ChannelID(ctx context.Context, name string) (string, bool, error) {
+	return r.lookup(ctx, "channels", strings.TrimPrefix(strings.ToLower(name), "#"))
+}
+
+// lookup returns the Slack ID for 'key' in the list of 'kind' (users, groups or channels), retrieving the list if
+// it has not been cached or has expired. The resolver's lock is not held while the list is being retrieved so
+// concurrent lookups may occasionally retrieve the same list more than once.
+func (r *mentionResolver) lookup(ctx context.Context, kind string, key string) (string, bool, error) {
+
+	c, err := r.cached(kind)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	if c == nil || time.Now().After(c.expires) {
+
+		ids, err := r.fetch(ctx, kind)
+
+		if err != nil {
+			return "", false, fmt.Errorf("Failed to retrieve list of %s, %w", kind, err)
+		}
+
+		c = &mentionCache{
+			ids:     ids,
+			expires: time.Now().Add(r.ttl),
+		}
+
+		r.store(kind, c)
+	}
+
+	id, ok := c.ids[key]
+	return id, ok, nil
+}
+
+func (r *mentionResolver) cached(kind string) (*mentionCache, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch kind {
+	case "users":
+		return r.users, nil
+	case "groups":
+		return r.groups, nil
+	case "channels":
+		return r.channels, nil
+	default:
+		return nil, fmt.Errorf("Invalid kind '%s'", kind)
+	}
+}
+
+func (r *mentionResolver) store(kind string, c *mentionCache) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch kind {
+	case "users":
+		r.users = c
+	case "groups":
+		r.groups = c
+	case "channels":
+		r.channels = c
+	}
+}
+
+func (r *mentionResolver) fetch(ctx context.Context, kind string) (map[string]string, error) {
+
+	ids := make(map[string]string)
+
+	switch kind {
+	case "users":
+
+		err := paginate(ctx, r.api, "users.list", url.Values{}, func(body []byte) {
+
+			for _, m := range gjson.GetBytes(body, "members").Array() {
+
+				if m.Get("deleted").Bool() {
+					continue
+				}
+
+				id := m.Get("id").String()
+
+				for _, path := range []string{"name", "profile.display_name", "profile.email"} {
+
+					v := strings.ToLower(m.Get(path).String())
+
+					if v != "" {
+						ids[v] = id
+					}
+				}
+			}
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+	case "groups":
+
+		body, err := r.api(ctx, "usergroups.list", url.Values{})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, g := range gjson.GetBytes(body, "usergroups").Array() {
+			ids[strings.ToLower(g.Get("handle").String())] = g.Get("id").String()
+		}
+
+	case "channels":
+
+		args := url.Values{}
+		args.Set("types", "public_channel,private_channel")
+		args.Set("exclude_archived", "true")
+
+		err := paginate(ctx, r.api, "conversations.list", args, func(body []byte) {
+
+			for _, c := range gjson.GetBytes(body, "channels").Array() {
+				ids[strings.ToLower(c.Get("name").String())] = c.Get("id").String()
+			}
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,116 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMentionResolverResolve(t *testing.T) {
+
+	ctx := context.Background()
+
+	responses := map[string]string{
+		"users.list":         `{"ok":true,"members":[{"id":"U0123456789","name":"bob","profile":{"email":"bob@example.com"}}]}`,
+		"usergroups.list":    `{"ok":true,"usergroups":[{"id":"S0123456789","handle":"ops"}]}`,
+		"conversations.list": `{"ok":true,"channels":[{"id":"C0123456789","name":"general"}]}`,
+	}
+
+	tests := map[string]struct {
+		allow      string
+		failing    string
+		text       string
+		expected   string
+		unresolved []string
+		error      bool
+	}{
+		"users": {
+			allow:      "users",
+			text:       "cc @bob, @bob@example.com and @nobody",
+			expected:   "cc <@U0123456789>, <@U0123456789> and @nobody",
+			unresolved: []string{"@nobody"},
+		},
+		"groups are not looked up unless allowed": {
+			allow:      "users",
+			text:       "cc @ops",
+			expected:   "cc @ops",
+			unresolved: []string{"@ops"},
+		},
+		"groups": {
+			allow:      "users,groups",
+			text:       "cc @ops",
+			expected:   "cc <!subteam^S0123456789>",
+			unresolved: []string{},
+		},
+		"disallowed kinds are skipped": {
+			allow:      "here",
+			text:       "cc @bob in #general",
+			expected:   "cc @bob in #general",
+			unresolved: []string{},
+		},
+		"channels": {
+			allow:      "channels",
+			text:       "see #general, not #123 or `#general`",
+			expected:   "see <#C0123456789>, not #123 or `#general`",
+			unresolved: []string{},
+		},
+		"lookup failure": {
+			allow:      "users,groups",
+			failing:    "users.list",
+			text:       "cc @bob and @ops",
+			expected:   "cc @bob and <!subteam^S0123456789>",
+			unresolved: []string{"@bob"},
+			error:      true,
+		},
+	}
+
+	for name, tc := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			api := func(ctx context.Context, method string, args url.Values) ([]byte, error) {
+
+				if method == tc.failing {
+					return nil, fmt.Errorf("Failed to call %s", method)
+				}
+
+				body, ok := responses[method]
+
+				if !ok {
+					return nil, fmt.Errorf("Unexpected method %s", method)
+				}
+
+				return []byte(body), nil
+			}
+
+			policy, err := NewMentionPolicyFromString(tc.allow)
+
+			if err != nil {
+				t.Fatalf("Failed to create mention policy, %v", err)
+			}
+
+			r := newMentionResolver(api, time.Minute)
+
+			got, unresolved, err := r.Resolve(ctx, tc.text, policy)
+
+			if tc.error && err == nil {
+				t.Fatalf("Expected lookup error")
+			}
+
+			if !tc.error && err != nil {
+				t.Fatalf("Failed to resolve mentions, %v", err)
+			}
+
+			if got != tc.expected {
+				t.Fatalf("Expected %q but got %q", tc.expected, got)
+			}
+
+			if !reflect.DeepEqual(unresolved, tc.unresolved) {
+				t.Fatalf("Expected unresolved %v but got %v", tc.unresolved, unresolved)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-uid"
+	"github.com/tidwall/gjson"
+	"net/url"
+)
+
+// Metadata is structured data attached to a Slack message for consumption by other applications.
+type Metadata struct {
+	// EventType is the name of the event the metadata describes, for example "deploy_finished".
+	EventType string `json:"event_type"`
+	// EventPayload is the data associated with the event.
+	EventPayload map[string]any `json:"event_payload"`
+}
+
+// Message extends `broadcaster.Message` with Slack-specific properties. It is broadcast using the
+// `SlackBroadcaster.BroadcastSlackMessage` method.
+type Message struct {
+	*broadcaster.Message
+	// Metadata is optional structured data to attach to the message. If present it takes precedence over any
+	// metadata stored in the context used to broadcast the message.
+	Metadata *Metadata
+}
+
+// SlackMessageBroadcaster is implemented by broadcasters which can broadcast a `Message` and its Slack-specific
+// properties. It is implemented by `SlackBroadcaster` and by the `OutboxBroadcaster`, `DigestBroadcaster`,
+// `RouteBroadcaster` and `WorkspacesBroadcaster` wrappers which may be returned by the `NewSlackBroadcaster` and
+// related methods.
+type SlackMessageBroadcaster interface {
+	BroadcastSlackMessage(context.Context, *Message) (uid.UID, error)
+}
+
+// broadcastSlackMessage broadcasts 'msg' using 'br', a wrapper around one or more `SlackBroadcaster` instances, by
+// storing its metadata in 'ctx' where it will be read by the wrapped broadcasters.
+func broadcastSlackMessage(ctx context.Context, br broadcaster.Broadcaster, msg *Message) (uid.UID, error) {
+
+	if msg == nil || msg.Message == nil {
+		return nil, fmt.Errorf("Missing message")
+	}
+
+	if msg.Metadata != nil {
+		ctx = WithMetadata(ctx, msg.Metadata)
+	}
+
+	return br.BroadcastMessage(ctx, msg.Message)
+}
+
+// NewMetadataFromJSON returns a new `Metadata` instance derived from 'body', which is expected to be a JSON
+// object with "event_type" and "event_payload" properties.
+func NewMetadataFromJSON(body []byte) (*Metadata, error) {
+
+	var md *Metadata
+
+	err := json.Unmarshal(body, &md)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal metadata, %w", err)
+	}
+
+	err = md.Validate()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return md, nil
+}
+
+// Validate ensures that 'md' has an event type.
+func (md *Metadata) Validate() error {
+
+	if md == nil || md.EventType == "" {
+		return fmt.Errorf("Metadata is missing an event type")
+	}
+
+	return nil
+}
+
+// attachMetadata assigns 'md' to the first of 'payloads' which is posted using the chat.postMessage or
+// chat.scheduleMessage API methods, since Slack does not support message metadata for other API methods. For
+// example if a message includes images the metadata is attached to the first part of the message text.
+func attachMetadata(payloads []*payload, md *Metadata) error {
+
+	if len(payloads) == 0 {
+		return fmt.Errorf("Nothing to attach metadata to")
+	}
+
+	var p *payload
+
+	for _, candidate := range payloads {
+
+		switch candidate.method {
+		case "chat.postMessage", "chat.scheduleMessage":
+			p = candidate
+		}
+
+		if p != nil {
+			break
+		}
+	}
+
+	if p == nil {
+		return fmt.Errorf("Metadata can not be attached to messages posted using %s", payloads[0].method)
+	}
+
+	event_payload := md.EventPayload
+
+	if event_payload == nil {
+		event_payload = make(map[string]any)
+	}
+
+	enc, err := json.Marshal(&Metadata{
+		EventType:    md.EventType,
+		EventPayload: event_payload,
+	})
+
+	if err != nil {
+		return fmt.Errorf("Failed to marshal metadata, %w", err)
+	}
+
+	p.args.Set("metadata", string(enc))
+	return nil
+}
+
+// GetMetadata returns the metadata attached to the message identified by 'id' using the conversations.history
+// API method. It returns nil if the message does not have any metadata.
+func (br *SlackBroadcaster) GetMetadata(ctx context.Context, id uid.UID) (*Metadata, error) {
+
+	channel, ts, err := messageRef(ctx, id)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive message from UID, %w", err)
+	}
+
+	args := url.Values{}
+	args.Set("channel", channel)
+	args.Set("latest", ts)
+	args.Set("oldest", ts)
+	args.Set("inclusive", "true")
+	args.Set("limit", "1")
+	args.Set("include_all_metadata", "true")
+
+	body, err := br.api(ctx, "conversations.history", args)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve message, %w", err)
+	}
+
+	msg := gjson.GetBytes(body, "messages.0")
+
+	if !msg.Exists() || msg.Get("ts").String() != ts {
+		return nil, fmt.Errorf("Message %s not found", id.String())
+	}
+
+	md_rsp := msg.Get("metadata")
+
+	if !md_rsp.Exists() {
+		return nil, nil
+	}
+
+	return NewMetadataFromJSON([]byte(md_rsp.Raw))
+}
@@ -0,0 +1,315 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-roster"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Outcomes of a Slack API call, used as the value of the `KeyOutcome` tag.
+const (
+	OUTCOME_OK           string = "ok"
+	OUTCOME_SLACK_ERROR  string = "slack_error"
+	OUTCOME_HTTP_ERROR   string = "http_error"
+	OUTCOME_RATE_LIMITED string = "rate_limited"
+	OUTCOME_ERROR        string = "error"
+)
+
+// Reasons a message was dropped, used as the value of the `KeyReason` tag.
+const (
+	// DROPPED_DIGEST signals that a digest, and every message it contained, could not be posted.
+	DROPPED_DIGEST string = "digest"
+	// DROPPED_OUTBOX signals that an outbox item reached the maximum number of delivery attempts.
+	DROPPED_OUTBOX string = "outbox"
+)
+
+var (
+	// KeyMethod is the tag key for the Slack API method being called.
+	KeyMethod = tag.MustNewKey("slack.method")
+	// KeyOutcome is the tag key for the outcome of a Slack API call.
+	KeyOutcome = tag.MustNewKey("slack.outcome")
+	// KeyReason is the tag key for the reason a message was dropped.
+	KeyReason = tag.MustNewKey("slack.reason")
+)
+
+var (
+	// MeasureAPICalls counts Slack API calls, including retries.
+	MeasureAPICalls = stats.Int64("github.com/aaronland/go-broadcaster-slack/api_calls", "The number of Slack API calls", stats.UnitDimensionless)
+	// MeasureAPILatency records the time taken by each Slack API call, excluding time spent waiting on the rate limiter.
+	MeasureAPILatency = stats.Float64("github.com/aaronland/go-broadcaster-slack/api_latency", "The latency of Slack API calls", stats.UnitMilliseconds)
+	// MeasureRetries counts Slack API calls which were retries of an earlier attempt.
+	MeasureRetries = stats.Int64("github.com/aaronland/go-broadcaster-slack/retries", "The number of retried Slack API calls", stats.UnitDimensionless)
+	// MeasureRateLimited counts Slack API calls which Slack rate limited (responded to with a 429 status code).
+	MeasureRateLimited = stats.Int64("github.com/aaronland/go-broadcaster-slack/rate_limited", "The number of Slack API calls rate limited by Slack", stats.UnitDimensionless)
+	// MeasureBytesSent records the number of bytes sent in the body of each Slack API call, including file uploads.
+	MeasureBytesSent = stats.Int64("github.com/aaronland/go-broadcaster-slack/bytes_sent", "The number of bytes sent to the Slack API", stats.UnitBytes)
+	// MeasureMessagesDropped counts messages which will never be posted.
+	MeasureMessagesDropped = stats.Int64("github.com/aaronland/go-broadcaster-slack/messages_dropped", "The number of messages which were dropped", stats.UnitDimensionless)
+)
+
+var (
+	// APICallsView is the number of Slack API calls by method and outcome.
+	APICallsView = &view.View{
+		Name:        "slack/api_calls",
+		Description: "The number of Slack API calls by method and outcome",
+		Measure:     MeasureAPICalls,
+		TagKeys:     []tag.Key{KeyMethod, KeyOutcome},
+		Aggregation: view.Count(),
+	}
+
+	// APILatencyView is the distribution of Slack API call latencies, in milliseconds, by method.
+	APILatencyView = &view.View{
+		Name:        "slack/api_latency",
+		Description: "The distribution of Slack API call latencies by method",
+		Measure:     MeasureAPILatency,
+		TagKeys:     []tag.Key{KeyMethod},
+		Aggregation: view.Distribution(10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000),
+	}
+
+	// RetriesView is the number of retried Slack API calls by method.
+	RetriesView = &view.View{
+		Name:        "slack/retries",
+		Description: "The number of retried Slack API calls by method",
+		Measure:     MeasureRetries,
+		TagKeys:     []tag.Key{KeyMethod},
+		Aggregation: view.Count(),
+	}
+
+	// RateLimitedView is the number of Slack API calls rate limited by Slack, by method.
+	RateLimitedView = &view.View{
+		Name:        "slack/rate_limited",
+		Description: "The number of Slack API calls rate limited by Slack by method",
+		Measure:     MeasureRateLimited,
+		TagKeys:     []tag.Key{KeyMethod},
+		Aggregation: view.Count(),
+	}
+
+	// BytesSentView is the total number of bytes sent to the Slack API, by method.
+	BytesSentView = &view.View{
+		Name:        "slack/bytes_sent",
+		Description: "The number of bytes sent to the Slack API by method",
+		Measure:     MeasureBytesSent,
+		TagKeys:     []tag.Key{KeyMethod},
+		Aggregation: view.Sum(),
+	}
+
+	// MessagesDroppedView is the number of dropped messages by reason.
+	MessagesDroppedView = &view.View{
+		Name:        "slack/messages_dropped",
+		Description: "The number of messages which were dropped by reason",
+		Measure:     MeasureMessagesDropped,
+		TagKeys:     []tag.Key{KeyReason},
+		Aggregation: view.Sum(),
+	}
+)
+
+// Views returns the OpenCensus views defined by this package.
+func Views() []*view.View {
+
+	return []*view.View{
+		APICallsView,
+		APILatencyView,
+		RetriesView,
+		RateLimitedView,
+		BytesSentView,
+		MessagesDroppedView,
+	}
+}
+
+// RegisterViews registers the OpenCensus views defined by this package. Measurements are only aggregated,
+// and passed to exporters, once the views have been registered.
+func RegisterViews() error {
+	return view.Register(Views()...)
+}
+
+// UnregisterViews unregisters the OpenCensus views defined by this package.
+func UnregisterViews() {
+	view.Unregister(Views()...)
+}
+
+// recordAPICall records the measurements for 'call'.
+func recordAPICall(ctx context.Context, call *apiCall) {
+
+	outcome := OUTCOME_OK
+
+	switch {
+	case call.err != nil:
+		outcome = OUTCOME_ERROR
+	case call.status == http.StatusTooManyRequests:
+		outcome = OUTCOME_RATE_LIMITED
+	case call.status != http.StatusOK:
+		outcome = OUTCOME_HTTP_ERROR
+	case call.code != "":
+		outcome = OUTCOME_SLACK_ERROR
+	}
+
+	mutators := []tag.Mutator{
+		tag.Upsert(KeyMethod, call.method),
+		tag.Upsert(KeyOutcome, outcome),
+	}
+
+	measurements := []stats.Measurement{
+		MeasureAPICalls.M(1),
+		MeasureAPILatency.M(float64(call.latency) / float64(time.Millisecond)),
+		MeasureBytesSent.M(call.request_bytes),
+	}
+
+	if call.attempt > 1 {
+		measurements = append(measurements, MeasureRetries.M(1))
+	}
+
+	if outcome == OUTCOME_RATE_LIMITED {
+		measurements = append(measurements, MeasureRateLimited.M(1))
+	}
+
+	stats.RecordWithTags(ctx, mutators, measurements...)
+}
+
+// recordDropped records that 'count' messages were dropped for 'reason'.
+func recordDropped(ctx context.Context, reason string, count int) {
+	stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(KeyReason, reason)}, MeasureMessagesDropped.M(int64(count)))
+}
+
+var metrics_exporter_roster roster.Roster
+
+// MetricsExporterInitializationFunc is a function defined by individual metrics exporter package and used to create
+// an instance of that exporter.
+type MetricsExporterInitializationFunc func(ctx context.Context, uri string) (view.Exporter, error)
+
+// RegisterMetricsExporter registers 'scheme' as a key pointing to 'init_func' in an internal lookup table
+// used to create new `view.Exporter` instances by the `NewMetricsExporter` method.
+func RegisterMetricsExporter(ctx context.Context, scheme string, init_func MetricsExporterInitializationFunc) error {
+
+	err := ensureMetricsExporterRoster()
+
+	if err != nil {
+		return err
+	}
+
+	return metrics_exporter_roster.Register(ctx, scheme, init_func)
+}
+
+func ensureMetricsExporterRoster() error {
+
+	if metrics_exporter_roster == nil {
+
+		r, err := roster.NewDefaultRoster()
+
+		if err != nil {
+			return err
+		}
+
+		metrics_exporter_roster = r
+	}
+
+	return nil
+}
+
+// NewMetricsExporter returns a new `view.Exporter` instance configured by 'uri'. The value of 'uri' is parsed
+// as a `url.URL` and its scheme is used as the key for a corresponding `MetricsExporterInitializationFunc`
+// function used to instantiate the new exporter. It is assumed that the scheme (and initialization
+// function) have been registered by the `RegisterMetricsExporter` method.
+func NewMetricsExporter(ctx context.Context, uri string) (view.Exporter, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = ensureMetricsExporterRoster()
+
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := metrics_exporter_roster.Driver(ctx, u.Scheme)
+
+	if err != nil {
+		return nil, err
+	}
+
+	init_func := i.(MetricsExporterInitializationFunc)
+	return init_func(ctx, uri)
+}
+
+// MetricsExporterSchemes returns the list of metrics exporter schemes that have been registered.
+func MetricsExporterSchemes() []string {
+
+	ctx := context.Background()
+	schemes := []string{}
+
+	err := ensureMetricsExporterRoster()
+
+	if err != nil {
+		return schemes
+	}
+
+	for _, dr := range metrics_exporter_roster.Drivers(ctx) {
+		scheme := fmt.Sprintf("%s://", strings.ToLower(dr))
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+	return schemes
+}
+
+// StartMetrics registers the views defined by this package and the `view.Exporter` instance configured by 'uri'.
+// If 'uri' contains an "?interval=" parameter it is used as the (process-wide) reporting period for views. The
+// returned function unregisters the exporter and the views, and should be called before the process exits.
+func StartMetrics(ctx context.Context, uri string) (func(), error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse metrics URI, %w", err)
+	}
+
+	q := u.Query()
+
+	if q.Has("interval") {
+
+		d, err := time.ParseDuration(q.Get("interval"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?interval= parameter, %w", err)
+		}
+
+		if d <= 0 {
+			return nil, fmt.Errorf("Invalid ?interval= parameter, must be greater than zero")
+		}
+
+		view.SetReportingPeriod(d)
+	}
+
+	ex, err := NewMetricsExporter(ctx, uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create metrics exporter, %w", err)
+	}
+
+	err = RegisterViews()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to register views, %w", err)
+	}
+
+	view.RegisterExporter(ex)
+
+	stop := func() {
+
+		// Unregistering the views reports their final data to the exporter
+		UnregisterViews()
+		view.UnregisterExporter(ex)
+	}
+
+	return stop, nil
+}
@@ -0,0 +1,66 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"go.opencensus.io/stats/view"
+	"log"
+	"strings"
+)
+
+func init() {
+	ctx := context.Background()
+	RegisterMetricsExporter(ctx, "log", NewLogMetricsExporter)
+}
+
+// LogMetricsExporter implements the `view.Exporter` interface by writing view data to a `log.Logger` instance.
+type LogMetricsExporter struct {
+	view.Exporter
+	logger *log.Logger
+}
+
+// NewLogMetricsExporter returns a new `LogMetricsExporter` instance configured by 'uri' which is expected
+// to take the form of:
+//
+//	log://?{PARAMETERS}
+//
+// Where {PARAMETERS} may be:
+// * `?interval=` An optional duration string (for example "10s") used as the reporting period for views.
+//
+// View data is written to the default `log.Logger`.
+func NewLogMetricsExporter(ctx context.Context, uri string) (view.Exporter, error) {
+
+	ex := &LogMetricsExporter{
+		logger: log.Default(),
+	}
+
+	return ex, nil
+}
+
+// ExportView writes each row of 'vd' to the exporter's logger.
+func (ex *LogMetricsExporter) ExportView(vd *view.Data) {
+
+	for _, row := range vd.Rows {
+
+		tags := make([]string, len(row.Tags))
+
+		for idx, t := range row.Tags {
+			tags[idx] = fmt.Sprintf("%s=%s", t.Key.Name(), t.Value)
+		}
+
+		value := ""
+
+		switch data := row.Data.(type) {
+		case *view.CountData:
+			value = fmt.Sprintf("count=%d", data.Value)
+		case *view.SumData:
+			value = fmt.Sprintf("sum=%v", data.Value)
+		case *view.LastValueData:
+			value = fmt.Sprintf("value=%v", data.Value)
+		case *view.DistributionData:
+			value = fmt.Sprintf("count=%d mean=%.2f min=%.2f max=%.2f", data.Count, data.Mean, data.Min, data.Max)
+		}
+
+		ex.logger.Printf("[metrics] %s %s %s\n", vd.View.Name, strings.Join(tags, " "), value)
+	}
+}
@@ -0,0 +1,563 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/sfomuseum/runtimevar"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// uri_params is the list of query parameters supported by `NewSlackBroadcaster` URIs.
+var uri_params = []string{
+	"credentials",
+	"team",
+	"allow-mentions",
+	"blocks",
+	"resolve-mentions",
+	"mention-cache-ttl",
+	"unresolved-mentions",
+	"max-length",
+	"overflow",
+	"snippet-lines",
+	"snippet-type",
+	"post-at",
+	"ephemeral-user",
+	"username",
+	"icon-emoji",
+	"icon-url",
+	"unfurl-links",
+	"unfurl-media",
+	"link-names",
+	"mrkdwn",
+	"parse",
+	"pin",
+	"debug",
+	"dry-run",
+	"max-retries",
+	"idempotency",
+	"idempotency-window",
+	"timeout",
+	"proxy",
+	"ca-bundle",
+	"rate-limit",
+	"channel-rate",
+	"channel-burst",
+	"digest",
+	"digest-max",
+	"outbox",
+	"outbox-poll",
+	"outbox-backoff",
+	"outbox-max-backoff",
+	"outbox-max-attempts",
+}
+
+// Options defines the settings used to create a new `SlackBroadcaster` with the `NewSlackBroadcasterWithOptions`
+// method. Timeout and RateLimiter guard against hung connections and rate limit errors so their zero values mean
+// the default settings; use NO_TIMEOUT and NoRateLimit to disable them. Likewise a zero SnippetLines means the default
+// of 10 lines; use NO_SNIPPET_LINES for none. The zero value of every other property, other than Channel and Token,
+// is a valid setting. Use `DefaultOptions` to start from the same defaults as
+// `NewSlackBroadcaster`.
+type Options struct {
+	// The name or ID of the channel to post messages to.
+	Channel string
+	// A Slack API OAuth token.
+	Token string
+	// The ID of the workspace to post messages to. Required for organization-wide (Enterprise Grid) tokens.
+	Team string
+	// The logger to use. If nil `log.Default()` is used.
+	Logger *log.Logger
+	// The structured logger used to log API calls. If nil API calls are logged, as text records, to the same destination as Logger.
+	StructuredLogger *slog.Logger
+	// Log the (redacted) headers and bodies of API requests and responses. Only applies if StructuredLogger is nil.
+	Debug bool
+	// Write the API requests which would be made to post messages to DryRunWriter, or Logger, instead of sending them.
+	// The idempotency key store and the outbox are not used in dry-run mode.
+	// No API calls are made in dry-run mode so ResolveMentions is ignored.
+	DryRun bool
+	// The writer used to write API requests in dry-run mode. If nil requests are written to Logger.
+	DryRunWriter io.Writer
+	// The HTTP client used to make API requests. If nil a client is created using Timeout, Proxy and CABundle.
+	HTTPClient *http.Client
+	// The amount of time to wait for an API request to complete. Zero means the default of 30 seconds and NO_TIMEOUT
+	// means no timeout.
+	Timeout time.Duration
+	// The URL of a proxy server to send API requests through.
+	Proxy string
+	// The path to a file containing PEM-encoded certificates to trust in addition to the system certificates.
+	CABundle string
+	// The kinds of special mentions which are allowed to pass through unescaped. If nil no mentions are allowed.
+	AllowMentions *MentionPolicy
+	// Also post messages as Block Kit blocks.
+	Blocks bool
+	// Resolve "@handle", "@email" and "#channel" references in message text in to Slack mention tokens.
+	ResolveMentions bool
+	// The amount of time lists used to resolve mentions are cached for. Zero means the default of 15 minutes.
+	MentionCacheTTL time.Duration
+	// What to do with references that can not be resolved. One of UNRESOLVED_KEEP (the default), UNRESOLVED_WARN or UNRESOLVED_ERROR.
+	UnresolvedMentions string
+	// The maximum number of characters to post in a single Slack message. Zero means the default of 4000.
+	MaxLength int
+	// How to post messages longer than MaxLength. One of OVERFLOW_MESSAGES (the default), OVERFLOW_THREAD or OVERFLOW_SNIPPET.
+	Overflow string
+	// The number of lines of the message body to include in the summary posted with a snippet. Zero means the default
+	// of 10 and NO_SNIPPET_LINES means none.
+	SnippetLines int
+	// The type of snippet to upload. One of the SNIPPET_ constants. Empty means SNIPPET_AUTO.
+	SnippetType string
+	// An RFC3339 timestamp, Unix timestamp or duration at (or after) which to schedule messages for delivery.
+	PostAt string
+	// The ID of a user to post messages as ephemeral messages for.
+	EphemeralUser string
+	// A custom username and icon to post messages as.
+	Identity *Identity
+	// Options for how Slack treats posted messages.
+	PostOptions *PostOptions
+	// Pin messages to the channel after they have been posted.
+	Pin bool
+	// The key store used to record messages which have been broadcast. If nil duplicates are not suppressed.
+	IdempotencyStore KeyStore
+	// The amount of time during which a message with the same idempotency key is a duplicate. Zero means the default of one hour.
+	IdempotencyWindow time.Duration
+	// The rate limiter API requests wait on. If nil the shared rate limiter for `DefaultRateLimits` is used.
+	RateLimiter *RateLimiter
+	// Do not rate limit API requests. If true RateLimiter is ignored.
+	NoRateLimit bool
+	// The number of times to retry a request which is rate limited by Slack. File uploads are not retried.
+	MaxRetries int
+	// The path to a directory used to store messages before they are sent. If empty no outbox is used.
+	Outbox string
+	// The retry settings for the outbox.
+	OutboxOptions *OutboxOptions
+	// If greater than zero messages are collected and posted as a single digest every Digest interval.
+	Digest time.Duration
+	// The maximum number of messages to collect before a digest is posted. Zero means the default of 100.
+	DigestMax int
+}
+
+// DefaultOptions returns a new `Options` instance for 'channel' and 'token' with the same default settings
+// as `NewSlackBroadcaster`.
+func DefaultOptions(channel string, token string) *Options {
+
+	opts := &Options{
+		Channel:      channel,
+		Token:        token,
+		Timeout:      default_http_timeout,
+		SnippetLines: default_snippet_lines,
+	}
+
+	l, err := SharedRateLimiter(DefaultRateLimits())
+
+	if err == nil {
+		opts.RateLimiter = l
+	}
+
+	return opts
+}
+
+// NewOptionsFromURI returns a new `Options` instance derived from 'uri' which is expected to take the form of:
+//
+//	slack://{CHANNEL}?credentials={RUNTIMEVAR_URI}&{PARAMETERS}
+//
+// Unknown query parameters are treated as an error.
+func NewOptionsFromURI(ctx context.Context, uri string) (*Options, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	return newOptionsFromURL(ctx, u, nil)
+}
+
+// newOptionsFromURL returns a new `Options` instance derived from 'u'. If 'tokens' is not nil it is used as a cache
+// of ?credentials= URIs and the tokens they dereference to, so that destinations which share credentials only
+// dereference them once.
+func newOptionsFromURL(ctx context.Context, u *url.URL, tokens map[string]string) (*Options, error) {
+
+	q := u.Query()
+
+	err := checkURIParams(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	creds_uri := q.Get("credentials")
+
+	if creds_uri == "" {
+		return nil, fmt.Errorf("Missing ?credentials= parameter")
+	}
+
+	token, ok := tokens[creds_uri]
+
+	if !ok {
+
+		rt_ctx, rt_cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer rt_cancel()
+
+		v, err := runtimevar.StringVar(rt_ctx, creds_uri)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive URI from credentials, %w", err)
+		}
+
+		token = v
+
+		if tokens != nil {
+			tokens[creds_uri] = token
+		}
+	}
+
+	opts := DefaultOptions(u.Host, token)
+
+	mentions, err := NewMentionPolicyFromString(q.Get("allow-mentions"))
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid ?allow-mentions= parameter, %w", err)
+	}
+
+	opts.AllowMentions = mentions
+
+	bools := map[string]*bool{
+		"blocks":           &opts.Blocks,
+		"resolve-mentions": &opts.ResolveMentions,
+		"pin":              &opts.Pin,
+		"debug":            &opts.Debug,
+		"dry-run":          &opts.DryRun,
+	}
+
+	for k, ptr := range bools {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		v, err := strconv.ParseBool(q.Get(k))
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?%s= parameter, %w", k, err)
+		}
+
+		*ptr = v
+	}
+
+	durations := map[string]*time.Duration{
+		"mention-cache-ttl":  &opts.MentionCacheTTL,
+		"idempotency-window": &opts.IdempotencyWindow,
+		"timeout":            &opts.Timeout,
+		"digest":             &opts.Digest,
+	}
+
+	for k, ptr := range durations {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		v, err := time.ParseDuration(q.Get(k))
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?%s= parameter, %w", k, err)
+		}
+
+		if v < 0 {
+			return nil, fmt.Errorf("Invalid ?%s= parameter, must not be negative", k)
+		}
+
+		*ptr = v
+	}
+
+	ints := map[string]*int{
+		"max-length":    &opts.MaxLength,
+		"snippet-lines": &opts.SnippetLines,
+		"digest-max":    &opts.DigestMax,
+		"max-retries":   &opts.MaxRetries,
+	}
+
+	for k, ptr := range ints {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		v, err := strconv.Atoi(q.Get(k))
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?%s= parameter, %w", k, err)
+		}
+
+		if v < 0 {
+			return nil, fmt.Errorf("Invalid ?%s= parameter, must not be negative", k)
+		}
+
+		*ptr = v
+	}
+
+	if q.Has("max-length") && opts.MaxLength == 0 {
+		return nil, fmt.Errorf("Invalid ?max-length= parameter, must be greater than zero")
+	}
+
+	if q.Has("timeout") && opts.Timeout == 0 {
+		opts.Timeout = NO_TIMEOUT
+	}
+
+	if q.Has("snippet-lines") && opts.SnippetLines == 0 {
+		opts.SnippetLines = NO_SNIPPET_LINES
+	}
+
+	if q.Has("digest") && opts.Digest == 0 {
+		return nil, fmt.Errorf("Invalid ?digest= parameter, must be greater than zero")
+	}
+
+	opts.UnresolvedMentions = q.Get("unresolved-mentions")
+	opts.Overflow = q.Get("overflow")
+	opts.SnippetType = q.Get("snippet-type")
+	opts.PostAt = q.Get("post-at")
+	opts.EphemeralUser = q.Get("ephemeral-user")
+	opts.Proxy = q.Get("proxy")
+	opts.CABundle = q.Get("ca-bundle")
+	opts.Outbox = q.Get("outbox")
+	opts.Team = q.Get("team")
+
+	opts.Identity = &Identity{
+		Username:  q.Get("username"),
+		IconEmoji: q.Get("icon-emoji"),
+		IconURL:   q.Get("icon-url"),
+	}
+
+	post_options, err := newPostOptionsFromQuery(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts.PostOptions = post_options
+
+	if q.Get("idempotency") != "" {
+
+		s, err := NewKeyStore(ctx, q.Get("idempotency"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?idempotency= parameter, %w", err)
+		}
+
+		opts.IdempotencyStore = s
+	}
+
+	rate_limiter, err := newRateLimiterFromQuery(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A nil rate limiter means that ?rate-limit=false
+
+	opts.RateLimiter = rate_limiter
+	opts.NoRateLimit = rate_limiter == nil
+
+	outbox_opts, err := newOutboxOptionsFromQuery(q)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts.OutboxOptions = outbox_opts
+
+	return opts, nil
+}
+
+// validate ensures that 'opts' is valid, assigning default values to properties which need them.
+func (opts *Options) validate() error {
+
+	if opts.Channel == "" {
+		return fmt.Errorf("Missing channel")
+	}
+
+	if opts.Team != "" {
+
+		err := validateTeamID(opts.Team)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.AllowMentions == nil {
+
+		p, err := NewMentionPolicy()
+
+		if err != nil {
+			return err
+		}
+
+		opts.AllowMentions = p
+	}
+
+	if opts.MentionCacheTTL == 0 {
+		opts.MentionCacheTTL = default_mention_cache_ttl
+	}
+
+	switch opts.UnresolvedMentions {
+	case "":
+		opts.UnresolvedMentions = UNRESOLVED_KEEP
+	case UNRESOLVED_KEEP, UNRESOLVED_WARN, UNRESOLVED_ERROR:
+		// pass
+	default:
+		return fmt.Errorf("Invalid unresolved mentions setting '%s'", opts.UnresolvedMentions)
+	}
+
+	if opts.MaxLength < 0 {
+		return fmt.Errorf("Invalid maximum length, must not be negative")
+	}
+
+	if opts.MaxLength == 0 {
+		opts.MaxLength = default_max_length
+	}
+
+	switch opts.Overflow {
+	case "":
+		opts.Overflow = OVERFLOW_MESSAGES
+	case OVERFLOW_MESSAGES, OVERFLOW_THREAD, OVERFLOW_SNIPPET:
+		// pass
+	default:
+		return fmt.Errorf("Invalid overflow setting '%s'", opts.Overflow)
+	}
+
+	if opts.SnippetLines < 0 && opts.SnippetLines != NO_SNIPPET_LINES {
+		return fmt.Errorf("Invalid snippet lines, must not be negative")
+	}
+
+	if opts.SnippetLines == 0 {
+		opts.SnippetLines = default_snippet_lines
+	}
+
+	if opts.SnippetType == "" {
+		opts.SnippetType = SNIPPET_AUTO
+	}
+
+	if !isValidSnippetType(opts.SnippetType) {
+		return fmt.Errorf("Invalid snippet type '%s'", opts.SnippetType)
+	}
+
+	if opts.PostAt != "" {
+
+		_, err := parsePostAt(opts.PostAt, time.Now())
+
+		if err != nil {
+			return fmt.Errorf("Invalid post at setting, %w", err)
+		}
+	}
+
+	if opts.Identity == nil {
+		opts.Identity = &Identity{}
+	}
+
+	err := opts.Identity.Validate()
+
+	if err != nil {
+		return fmt.Errorf("Invalid identity, %w", err)
+	}
+
+	if opts.PostOptions == nil {
+		opts.PostOptions = &PostOptions{}
+	}
+
+	err = opts.PostOptions.Validate()
+
+	if err != nil {
+		return fmt.Errorf("Invalid post options, %w", err)
+	}
+
+	err = opts.PostOptions.checkMentions(opts.AllowMentions)
+
+	if err != nil {
+		return fmt.Errorf("Invalid post options, %w", err)
+	}
+
+	if opts.IdempotencyWindow < 0 {
+		return fmt.Errorf("Invalid idempotency window, must not be negative")
+	}
+
+	if opts.IdempotencyWindow == 0 {
+		opts.IdempotencyWindow = default_idempotency_window
+	}
+
+	if opts.Timeout < 0 && opts.Timeout != NO_TIMEOUT {
+		return fmt.Errorf("Invalid timeout, must not be negative")
+	}
+
+	if opts.Timeout == 0 {
+		opts.Timeout = default_http_timeout
+	}
+
+	if opts.NoRateLimit {
+		opts.RateLimiter = nil
+	} else if opts.RateLimiter == nil {
+
+		l, err := SharedRateLimiter(DefaultRateLimits())
+
+		if err != nil {
+			return fmt.Errorf("Failed to create rate limiter, %w", err)
+		}
+
+		opts.RateLimiter = l
+	}
+
+	if opts.MaxRetries < 0 {
+		return fmt.Errorf("Invalid maximum retries, must not be negative")
+	}
+
+	if opts.Digest < 0 {
+		return fmt.Errorf("Invalid digest interval, must not be negative")
+	}
+
+	if opts.DigestMax < 0 {
+		return fmt.Errorf("Invalid maximum digest size, must not be negative")
+	}
+
+	if opts.DigestMax == 0 {
+		opts.DigestMax = default_digest_max
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+
+	return nil
+}
+
+// checkURIParams returns an error listing any parameters in 'q' which are not supported.
+func checkURIParams(q url.Values) error {
+
+	known := make(map[string]bool)
+
+	for _, k := range uri_params {
+		known[k] = true
+	}
+
+	unknown := make([]string, 0)
+
+	for k := range q {
+
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	sort.Strings(unknown)
+
+	return fmt.Errorf("Unsupported parameter(s): %s", strings.Join(unknown, ", "))
+}
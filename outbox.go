@@ -0,0 +1,698 @@
+package slack
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-uid"
+	"image"
+	"image/png"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The name of the file, inside an outbox item's directory, containing the item's properties.
+const outbox_item_filename string = "item.json"
+
+// The name of the file, inside an outbox item's directory, used to signal that the item is being delivered.
+const outbox_lock_filename string = ".lock"
+
+// The prefix for outbox item directories which are still being written.
+const outbox_tmp_prefix string = ".tmp-"
+
+// The amount of time after which an item's lock file is assumed to have been left behind by a process
+// that was killed while delivering it.
+const outbox_stale_lock time.Duration = 10 * time.Minute
+
+// ErrOutboxItemLocked is returned when an outbox item is already being delivered.
+var ErrOutboxItemLocked = errors.New("Outbox item is locked")
+
+// ErrOutboxItemDelivered is returned when an outbox item has already been delivered but could not be removed.
+var ErrOutboxItemDelivered = errors.New("Outbox item has already been delivered")
+
+// ErrOutboxDestination is returned when an outbox item is delivered using a broadcaster which posts to a different
+// channel, or workspace, than the one the item was added for.
+var ErrOutboxDestination = errors.New("Outbox item was added for a different destination")
+
+// OutboxItem is a message waiting to be broadcast in an `Outbox`.
+type OutboxItem struct {
+	// The unique ID of the item. IDs sort in the order items were added.
+	ID string `json:"id"`
+	// The title of the message.
+	Title string `json:"title"`
+	// The body of the message.
+	Body string `json:"body"`
+	// The names of the (PNG-encoded) image files, relative to the item's directory, for the message.
+	Images []string `json:"images,omitempty"`
+	// The time the item was added to the outbox.
+	Created time.Time `json:"created"`
+	// The number of times delivery has been attempted.
+	Attempts int `json:"attempts"`
+	// The earliest time at which delivery should next be attempted.
+	NextAttempt time.Time `json:"next_attempt"`
+	// The error returned by the last delivery attempt, if any.
+	LastError string `json:"last_error,omitempty"`
+	// The per-message options, stored in the context used to add the item, which are restored for each
+	// delivery attempt.
+	Options *OutboxMessageOptions `json:"options,omitempty"`
+	// The channel, prefixed by the workspace ID if present, that the item was added for. Empty if the destination
+	// of the broadcaster used to add the item could not be determined.
+	Destination string `json:"destination,omitempty"`
+	// The string value of the UID of the delivered message, if the item was delivered but could not be removed.
+	Delivered string `json:"delivered,omitempty"`
+}
+
+// OutboxMessageOptions are the per-message options, assigned using methods like `WithEphemeralUser` or `WithPostAt`,
+// stored with an `OutboxItem` so that they apply to every delivery attempt and not just the first.
+type OutboxMessageOptions struct {
+	// The time the message should be delivered at, assigned using `WithPostAt`.
+	PostAt *time.Time `json:"post_at,omitempty"`
+	// The ID of the user an ephemeral message should be posted for, assigned using `WithEphemeralUser`.
+	EphemeralUser string `json:"ephemeral_user,omitempty"`
+	// The identity to post the message as, assigned using `WithIdentity`.
+	Identity *Identity `json:"identity,omitempty"`
+	// The options to post the message with, assigned using `WithPostOptions`.
+	PostOptions *PostOptions `json:"post_options,omitempty"`
+	// The metadata to attach to the message, assigned using `WithMetadata` or `WithMetadataJSON`.
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// The key used to identify duplicate messages, assigned using `WithIdempotencyKey`.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+// newOutboxMessageOptions returns the per-message options stored in 'ctx' or nil if there are none.
+func newOutboxMessageOptions(ctx context.Context) *OutboxMessageOptions {
+
+	opts := &OutboxMessageOptions{}
+	found := false
+
+	if t, ok := postAtFromContext(ctx); ok {
+		opts.PostAt = &t
+		found = true
+	}
+
+	if user, ok := ephemeralUserFromContext(ctx); ok {
+		opts.EphemeralUser = user
+		found = true
+	}
+
+	if id, ok := identityFromContext(ctx); ok {
+		opts.Identity = id
+		found = true
+	}
+
+	if post_opts, ok := postOptionsFromContext(ctx); ok {
+		opts.PostOptions = post_opts
+		found = true
+	}
+
+	if md, ok := metadataFromContext(ctx); ok {
+		opts.Metadata = md
+		found = true
+	}
+
+	if key, ok := idempotencyKeyFromContext(ctx); ok {
+		opts.IdempotencyKey = key
+		found = true
+	}
+
+	if !found {
+		return nil
+	}
+
+	return opts
+}
+
+// WithContext returns a copy of 'ctx' with each of the options defined by 'opts' assigned to it.
+func (opts *OutboxMessageOptions) WithContext(ctx context.Context) context.Context {
+
+	if opts == nil {
+		return ctx
+	}
+
+	if opts.PostAt != nil {
+		ctx = WithPostAt(ctx, *opts.PostAt)
+	}
+
+	if opts.EphemeralUser != "" {
+		ctx = WithEphemeralUser(ctx, opts.EphemeralUser)
+	}
+
+	if opts.Identity != nil {
+		ctx = WithIdentity(ctx, opts.Identity)
+	}
+
+	if opts.PostOptions != nil {
+		ctx = WithPostOptions(ctx, opts.PostOptions)
+	}
+
+	if opts.Metadata != nil {
+		ctx = WithMetadata(ctx, opts.Metadata)
+	}
+
+	if opts.IdempotencyKey != "" {
+		ctx = WithIdempotencyKey(ctx, opts.IdempotencyKey)
+	}
+
+	return ctx
+}
+
+// Outbox is a durable, directory-backed queue of messages waiting to be broadcast. Each item is stored
+// in its own directory containing the message text and its encoded images so that messages survive
+// outages and process restarts.
+type Outbox struct {
+	root   string
+	logger *log.Logger
+	mu     *sync.Mutex
+}
+
+// NewOutbox returns a new `Outbox` instance storing items in 'root', which is created if it does not exist.
+func NewOutbox(ctx context.Context, root string) (*Outbox, error) {
+
+	abs_root, err := filepath.Abs(root)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive absolute path for outbox, %w", err)
+	}
+
+	err = os.MkdirAll(abs_root, 0700)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create outbox directory, %w", err)
+	}
+
+	o := &Outbox{
+		root:   abs_root,
+		logger: log.Default(),
+		mu:     new(sync.Mutex),
+	}
+
+	return o, nil
+}
+
+// SetLogger assigns 'logger' to 'o'. It is used to report items which were delivered but could not be removed.
+func (o *Outbox) SetLogger(logger *log.Logger) {
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.logger = logger
+}
+
+func (o *Outbox) logf(format string, args ...any) {
+
+	o.mu.Lock()
+	logger := o.logger
+	o.mu.Unlock()
+
+	logger.Printf(format, args...)
+}
+
+// Enqueue adds 'msg' to the outbox, along with any per-message options stored in 'ctx', to be delivered using 'br'.
+// The channel, and workspace, that 'br' posts to are stored with the item and checked when it is delivered.
+func (o *Outbox) Enqueue(ctx context.Context, br broadcaster.Broadcaster, msg *broadcaster.Message) (*OutboxItem, error) {
+
+	item, unlock, err := o.enqueue(ctx, br, msg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	unlock()
+	return item, nil
+}
+
+// enqueue adds 'msg' to the outbox in the same way as `Enqueue` except that the item is locked before it becomes
+// visible to other readers, so that it is not delivered by anyone else, and a function to unlock it is returned.
+func (o *Outbox) enqueue(ctx context.Context, br broadcaster.Broadcaster, msg *broadcaster.Message) (*OutboxItem, func(), error) {
+
+	id, err := newOutboxID()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+
+	item := &OutboxItem{
+		ID:          id,
+		Title:       msg.Title,
+		Body:        msg.Body,
+		Images:      make([]string, len(msg.Images)),
+		Created:     now,
+		NextAttempt: now,
+		Options:     newOutboxMessageOptions(ctx),
+		Destination: outboxDestination(br),
+	}
+
+	// Write everything to a temporary directory first and then rename it so that a partially
+	// written item is never visible to other readers.
+
+	tmp_dir := filepath.Join(o.root, outbox_tmp_prefix+id)
+
+	err = os.Mkdir(tmp_dir, 0700)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to create item directory, %w", err)
+	}
+
+	defer os.RemoveAll(tmp_dir)
+
+	for idx, im := range msg.Images {
+
+		fname := fmt.Sprintf("image-%03d.png", idx)
+
+		err := writeOutboxImage(filepath.Join(tmp_dir, fname), im)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to write image %d, %w", idx, err)
+		}
+
+		item.Images[idx] = fname
+	}
+
+	err = writeOutboxItem(filepath.Join(tmp_dir, outbox_item_filename), item)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = os.WriteFile(filepath.Join(tmp_dir, outbox_lock_filename), []byte{}, 0600)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to lock item, %w", err)
+	}
+
+	err = os.Rename(tmp_dir, o.itemDir(id))
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to commit item, %w", err)
+	}
+
+	unlock := func() {
+		os.Remove(filepath.Join(o.itemDir(id), outbox_lock_filename))
+	}
+
+	return item, unlock, nil
+}
+
+// List returns all the items in the outbox, in the order they were added.
+func (o *Outbox) List(ctx context.Context) ([]*OutboxItem, error) {
+
+	entries, err := os.ReadDir(o.root)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read outbox, %w", err)
+	}
+
+	items := make([]*OutboxItem, 0)
+
+	for _, e := range entries {
+
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+
+		item, err := o.Get(ctx, e.Name())
+
+		if err != nil {
+
+			// The item may have been delivered, and removed, since the directory was read
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+
+	return items, nil
+}
+
+// Get returns the item with ID 'id'.
+func (o *Outbox) Get(ctx context.Context, id string) (*OutboxItem, error) {
+
+	err := validateOutboxID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := os.Open(filepath.Join(o.itemDir(id), outbox_item_filename))
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open item %s, %w", id, err)
+	}
+
+	defer r.Close()
+
+	var item *OutboxItem
+
+	err = json.NewDecoder(r).Decode(&item)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode item %s, %w", id, err)
+	}
+
+	return item, nil
+}
+
+// Message returns the `broadcaster.Message` stored by 'item'.
+func (o *Outbox) Message(ctx context.Context, item *OutboxItem) (*broadcaster.Message, error) {
+
+	msg := &broadcaster.Message{
+		Title: item.Title,
+		Body:  item.Body,
+	}
+
+	if len(item.Images) == 0 {
+		return msg, nil
+	}
+
+	msg.Images = make([]image.Image, len(item.Images))
+
+	for idx, fname := range item.Images {
+
+		r, err := os.Open(filepath.Join(o.itemDir(item.ID), filepath.Base(fname)))
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open image %d, %w", idx, err)
+		}
+
+		im, err := png.Decode(r)
+		r.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to decode image %d, %w", idx, err)
+		}
+
+		msg.Images[idx] = im
+	}
+
+	return msg, nil
+}
+
+// Deliver broadcasts the message stored by the item with ID 'id' using 'br', with the item's per-message options
+// assigned to 'ctx'. If successful the item is removed from the outbox, otherwise the item's delivery attempts and
+// last error are updated and its next attempt is scheduled according to 'backoff'. It returns `ErrOutboxItemLocked`
+// if the item is already being delivered and `ErrOutboxDestination` if 'br' posts to a different channel, or
+// workspace, than the one the item was added for. A scheduled time which has passed by the time the item is
+// delivered is ignored and the message is posted immediately. If the message is delivered but the item can not be
+// removed the failure is logged, the item is marked as delivered and the message's UID is returned without an error.
+func (o *Outbox) Deliver(ctx context.Context, br broadcaster.Broadcaster, id string, backoff func(int) time.Duration) (uid.UID, error) {
+
+	unlock, err := o.lock(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+
+	return o.deliver(ctx, br, id, backoff)
+}
+
+// deliver delivers the item with ID 'id' in the same way as `Deliver`. The caller is expected to hold the item's lock.
+func (o *Outbox) deliver(ctx context.Context, br broadcaster.Broadcaster, id string, backoff func(int) time.Duration) (uid.UID, error) {
+
+	// Read the item again now that we hold the lock in case it was delivered in the meantime
+
+	item, err := o.Get(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if item.Delivered != "" {
+
+		err := o.Remove(ctx, id)
+
+		if err != nil {
+			o.logf("Failed to remove outbox item %s which was delivered as %s, %v\n", id, item.Delivered, err)
+		}
+
+		return nil, fmt.Errorf("Outbox item %s was delivered as %s, %w", id, item.Delivered, ErrOutboxItemDelivered)
+	}
+
+	destination := outboxDestination(br)
+
+	if item.Destination != "" && destination != "" && item.Destination != destination {
+		return nil, fmt.Errorf("Outbox item %s was added for %s but the broadcaster posts to %s, %w", id, item.Destination, destination, ErrOutboxDestination)
+	}
+
+	msg, err := o.Message(ctx, item)
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts := item.Options
+
+	if opts != nil && opts.PostAt != nil && !opts.PostAt.After(time.Now()) {
+
+		// Slack does not schedule messages in the past so post the message now rather than failing forever
+
+		o.logf("Scheduled time %s for outbox item %s has passed, posting it immediately\n", opts.PostAt.Format(time.RFC3339), id)
+
+		unscheduled := *opts
+		unscheduled.PostAt = nil
+		opts = &unscheduled
+	}
+
+	msg_id, br_err := br.BroadcastMessage(opts.WithContext(ctx), msg)
+
+	if br_err == nil {
+
+		err := o.Remove(ctx, id)
+
+		if err != nil {
+
+			// The message has been posted so it must not be delivered again
+
+			o.logf("Message was delivered as %s but failed to remove outbox item %s, %v\n", msg_id.String(), id, err)
+
+			item.Delivered = msg_id.String()
+
+			err = writeOutboxItem(filepath.Join(o.itemDir(id), outbox_item_filename), item)
+
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				o.logf("Failed to mark outbox item %s as delivered, it may be delivered again, %v\n", id, err)
+			}
+		}
+
+		return msg_id, nil
+	}
+
+	item.Attempts += 1
+	item.LastError = br_err.Error()
+	item.NextAttempt = time.Now().Add(backoff(item.Attempts))
+
+	err = writeOutboxItem(filepath.Join(o.itemDir(id), outbox_item_filename), item)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to deliver item %s (%v) and failed to update item, %w", id, br_err, err)
+	}
+
+	return nil, fmt.Errorf("Failed to deliver item %s, %w", id, br_err)
+}
+
+// Remove removes the item with ID 'id' from the outbox.
+func (o *Outbox) Remove(ctx context.Context, id string) error {
+
+	err := validateOutboxID(id)
+
+	if err != nil {
+		return err
+	}
+
+	// Rename first so that the item disappears atomically even if removing its files fails
+
+	tmp_dir := filepath.Join(o.root, outbox_tmp_prefix+id)
+
+	err = os.Rename(o.itemDir(id), tmp_dir)
+
+	if err != nil {
+		return fmt.Errorf("Failed to remove item %s, %w", id, err)
+	}
+
+	return os.RemoveAll(tmp_dir)
+}
+
+// Purge removes all the items from the outbox and returns the number of items removed.
+func (o *Outbox) Purge(ctx context.Context) (int, error) {
+
+	items, err := o.List(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, item := range items {
+
+		err := o.Remove(ctx, item.ID)
+
+		if err != nil {
+			return count, err
+		}
+
+		count += 1
+	}
+
+	return count, nil
+}
+
+// outboxDestination returns the channel, prefixed by the workspace ID if present, that 'br' posts to or an empty
+// string if it can not be determined. Broadcasters wrapped by an `OutboxBroadcaster` or a `DigestBroadcaster` are
+// unwrapped first.
+func outboxDestination(br broadcaster.Broadcaster) string {
+
+	for {
+
+		switch b := br.(type) {
+		case *OutboxBroadcaster:
+			br = b.target
+		case *DigestBroadcaster:
+			br = b.target
+		case *SlackBroadcaster:
+
+			if b.team != "" {
+				return fmt.Sprintf("%s/%s", b.team, b.channel)
+			}
+
+			return b.channel
+		default:
+			return ""
+		}
+	}
+}
+
+func (o *Outbox) itemDir(id string) string {
+	return filepath.Join(o.root, id)
+}
+
+// lock creates a lock file for the item with ID 'id' and returns a function to remove it. Lock files older
+// than `outbox_stale_lock` are assumed to have been left behind by a process which was killed and are replaced.
+func (o *Outbox) lock(id string) (func(), error) {
+
+	err := validateOutboxID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(o.itemDir(id), outbox_lock_filename)
+
+	fh, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+
+	if errors.Is(err, fs.ErrExist) {
+
+		info, stat_err := os.Stat(path)
+
+		if stat_err != nil || time.Since(info.ModTime()) < outbox_stale_lock {
+			return nil, ErrOutboxItemLocked
+		}
+
+		os.Remove(path)
+		fh, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	}
+
+	if err != nil {
+
+		if errors.Is(err, fs.ErrExist) {
+			return nil, ErrOutboxItemLocked
+		}
+
+		return nil, fmt.Errorf("Failed to lock item %s, %w", id, err)
+	}
+
+	fh.Close()
+
+	unlock := func() {
+		os.Remove(path)
+	}
+
+	return unlock, nil
+}
+
+func newOutboxID() (string, error) {
+
+	b := make([]byte, 4)
+
+	_, err := rand.Read(b)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate random ID, %w", err)
+	}
+
+	return fmt.Sprintf("%020d-%s", time.Now().UnixNano(), hex.EncodeToString(b)), nil
+}
+
+func validateOutboxID(id string) error {
+
+	if id == "" || strings.HasPrefix(id, ".") || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("Invalid outbox item ID '%s'", id)
+	}
+
+	return nil
+}
+
+func writeOutboxImage(path string, im image.Image) error {
+
+	wr, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	err = png.Encode(wr, im)
+
+	if err != nil {
+		wr.Close()
+		return err
+	}
+
+	return wr.Close()
+}
+
+// writeOutboxItem writes 'item' to 'path' by way of a temporary file so that updates are atomic.
+func writeOutboxItem(path string, item *OutboxItem) error {
+
+	enc, err := json.MarshalIndent(item, "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode item, %w", err)
+	}
+
+	tmp_path := path + ".tmp"
+
+	err = os.WriteFile(tmp_path, enc, 0600)
+
+	if err != nil {
+		return fmt.Errorf("Failed to write item, %w", err)
+	}
+
+	err = os.Rename(tmp_path, path)
+
+	if err != nil {
+		return fmt.Errorf("Failed to commit item, %w", err)
+	}
+
+	return nil
+}
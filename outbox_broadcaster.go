@@ -0,0 +1,363 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-uid"
+	"log"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The default interval at which the outbox is checked for items to deliver.
+const default_outbox_poll time.Duration = 30 * time.Second
+
+// The default delay before the first retry of a failed delivery. Subsequent delays are doubled.
+const default_outbox_backoff time.Duration = 5 * time.Second
+
+// The default maximum delay between retries of a failed delivery.
+const default_outbox_max_backoff time.Duration = 30 * time.Minute
+
+// OutboxUID implements the `uid.UID` interface for a message which could not be delivered immediately
+// and is waiting to be retried from an `Outbox`.
+type OutboxUID struct {
+	uid.UID
+	id string
+}
+
+// NewOutboxUID returns a new `OutboxUID` for the outbox item with ID 'id'.
+func NewOutboxUID(ctx context.Context, id string) (uid.UID, error) {
+
+	err := validateOutboxID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u := &OutboxUID{
+		id: id,
+	}
+
+	return u, nil
+}
+
+// ID returns the ID of the outbox item.
+func (u *OutboxUID) ID() string {
+	return u.id
+}
+
+// Value returns the string representation of 'u'.
+func (u *OutboxUID) Value() any {
+	return u.String()
+}
+
+// String returns 'u' in the form of "outbox:{ITEM_ID}".
+func (u *OutboxUID) String() string {
+	return fmt.Sprintf("outbox:%s", u.id)
+}
+
+// OutboxOptions defines how an `OutboxBroadcaster` retries messages which could not be delivered.
+type OutboxOptions struct {
+	// The interval at which the outbox is checked for items to deliver.
+	PollInterval time.Duration
+	// The delay before the first retry of a failed delivery. Subsequent delays are doubled.
+	Backoff time.Duration
+	// The maximum delay between retries of a failed delivery.
+	MaxBackoff time.Duration
+	// The maximum number of delivery attempts after which an item is left in the outbox for manual
+	// inspection. Zero means items are retried indefinitely.
+	MaxAttempts int
+}
+
+// OutboxBroadcaster implements the `broadcaster.Broadcaster` interface by writing each message to an
+// `Outbox` before handing it to another `broadcaster.Broadcaster`. Messages which can not be delivered
+// are retried, with exponential backoff, by a background worker which also replays any items left in
+// the outbox when it is started.
+type OutboxBroadcaster struct {
+	broadcaster.Broadcaster
+	target  broadcaster.Broadcaster
+	outbox  *Outbox
+	options *OutboxOptions
+	// logger is guarded by logger_mu since it may be replaced by SetLogger while the worker is running
+	logger    *log.Logger
+	logger_mu *sync.Mutex
+	cancel    context.CancelFunc
+	done      chan bool
+	once      sync.Once
+}
+
+// NewOutboxBroadcaster returns a new `OutboxBroadcaster` instance which delivers messages stored in 'outbox'
+// using 'target' and starts its background worker. Callers should invoke the `Close` method to stop the worker.
+func NewOutboxBroadcaster(ctx context.Context, target broadcaster.Broadcaster, outbox *Outbox, opts *OutboxOptions) (*OutboxBroadcaster, error) {
+
+	if opts == nil {
+		opts = &OutboxOptions{}
+	}
+
+	options := *opts
+
+	if options.PollInterval <= 0 {
+		options.PollInterval = default_outbox_poll
+	}
+
+	if options.Backoff <= 0 {
+		options.Backoff = default_outbox_backoff
+	}
+
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = default_outbox_max_backoff
+	}
+
+	if options.MaxAttempts < 0 {
+		return nil, fmt.Errorf("Maximum attempts must be zero or greater")
+	}
+
+	// The worker outlives the context used to create the broadcaster so it gets its own
+
+	worker_ctx, cancel := context.WithCancel(context.Background())
+
+	br := &OutboxBroadcaster{
+		target:    target,
+		outbox:    outbox,
+		options:   &options,
+		logger:    log.Default(),
+		logger_mu: new(sync.Mutex),
+		cancel:    cancel,
+		done:      make(chan bool),
+	}
+
+	go br.run(worker_ctx)
+
+	return br, nil
+}
+
+// newOutboxOptionsFromQuery returns a new `OutboxOptions` instance configured using the "outbox" parameters in 'q'.
+func newOutboxOptionsFromQuery(q url.Values) (*OutboxOptions, error) {
+
+	opts := &OutboxOptions{}
+
+	durations := map[string]*time.Duration{
+		"outbox-poll":        &opts.PollInterval,
+		"outbox-backoff":     &opts.Backoff,
+		"outbox-max-backoff": &opts.MaxBackoff,
+	}
+
+	for k, ptr := range durations {
+
+		if !q.Has(k) {
+			continue
+		}
+
+		d, err := time.ParseDuration(q.Get(k))
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?%s= parameter, %w", k, err)
+		}
+
+		if d <= 0 {
+			return nil, fmt.Errorf("Invalid ?%s= parameter, must be greater than zero", k)
+		}
+
+		*ptr = d
+	}
+
+	if q.Has("outbox-max-attempts") {
+
+		v, err := strconv.Atoi(q.Get("outbox-max-attempts"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?outbox-max-attempts= parameter, %w", err)
+		}
+
+		opts.MaxAttempts = v
+	}
+
+	return opts, nil
+}
+
+// BroadcastMessage writes 'msg' to the outbox and then attempts to deliver it. If delivery fails the
+// message is left in the outbox to be retried and an `OutboxUID` is returned instead of an error. Per-message
+// options stored in 'ctx' are stored with the message and apply to every delivery attempt.
+func (br *OutboxBroadcaster) BroadcastMessage(ctx context.Context, msg *broadcaster.Message) (uid.UID, error) {
+
+	// The item is locked until the first delivery attempt has finished so that the background worker does
+	// not try to deliver it at the same time
+
+	item, unlock, err := br.outbox.enqueue(ctx, br.target, msg)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to add message to outbox, %w", err)
+	}
+
+	id, err := br.outbox.deliver(ctx, br.target, item.ID, br.backoff)
+	unlock()
+
+	if err == nil {
+		return id, nil
+	}
+
+	br.logf("Failed to deliver message, queued as outbox item %s for retry, %v\n", item.ID, err)
+
+	br.checkAttempts(ctx, item.ID, 1)
+
+	return NewOutboxUID(ctx, item.ID)
+}
+
+// BroadcastSlackMessage writes 'msg' to the outbox, along with its Slack-specific properties, and then attempts to
+// deliver it in the same way as the `BroadcastMessage` method.
+func (br *OutboxBroadcaster) BroadcastSlackMessage(ctx context.Context, msg *Message) (uid.UID, error) {
+	return broadcastSlackMessage(ctx, br, msg)
+}
+
+// SetLogger assigns 'logger' to 'br', its outbox and the broadcaster it delivers messages with. It is safe to call while
+// messages are being delivered.
+func (br *OutboxBroadcaster) SetLogger(ctx context.Context, logger *log.Logger) error {
+
+	br.logger_mu.Lock()
+	br.logger = logger
+	br.logger_mu.Unlock()
+
+	br.outbox.SetLogger(logger)
+	return br.target.SetLogger(ctx, logger)
+}
+
+// logf writes a message to the logger for 'br', which may be replaced by `SetLogger` at any time.
+func (br *OutboxBroadcaster) logf(format string, args ...any) {
+
+	br.logger_mu.Lock()
+	logger := br.logger
+	br.logger_mu.Unlock()
+
+	logger.Printf(format, args...)
+}
+
+// Outbox returns the `Outbox` instance used by 'br'.
+func (br *OutboxBroadcaster) Outbox() *Outbox {
+	return br.outbox
+}
+
+// Flush attempts to deliver every item in the outbox whose next attempt is due, ignoring the
+// maximum number of attempts if 'force' is true.
+func (br *OutboxBroadcaster) Flush(ctx context.Context, force bool) error {
+
+	items, err := br.outbox.List(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, item := range items {
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if !force {
+
+			if item.NextAttempt.After(now) {
+				continue
+			}
+
+			if br.options.MaxAttempts > 0 && item.Attempts >= br.options.MaxAttempts {
+				continue
+			}
+		}
+
+		id, err := br.outbox.Deliver(ctx, br.target, item.ID, br.backoff)
+
+		if err != nil {
+
+			if !errors.Is(err, ErrOutboxItemLocked) && !errors.Is(err, ErrOutboxItemDelivered) {
+				br.logf("Failed to deliver outbox item %s, %v\n", item.ID, err)
+				br.checkAttempts(ctx, item.ID, item.Attempts+1)
+			}
+
+			continue
+		}
+
+		br.logf("Delivered outbox item %s as %s\n", item.ID, id.String())
+	}
+
+	return nil
+}
+
+// Close stops the background worker, waiting for any delivery in progress to complete. Undelivered
+// items remain in the outbox and will be replayed the next time an `OutboxBroadcaster` is started.
+func (br *OutboxBroadcaster) Close() error {
+
+	br.once.Do(func() {
+		br.cancel()
+		<-br.done
+	})
+
+	return nil
+}
+
+// run replays any items left in the outbox and then checks for items to deliver every poll interval until 'ctx' is cancelled.
+func (br *OutboxBroadcaster) run(ctx context.Context) {
+
+	defer close(br.done)
+
+	ticker := time.NewTicker(br.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+
+		err := br.Flush(ctx, false)
+
+		if err != nil && ctx.Err() == nil {
+			br.logf("Failed to process outbox, %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// pass
+		}
+	}
+}
+
+// checkAttempts records outbox item 'id' as dropped if 'attempts' failed attempts have exhausted the maximum
+// number of delivery attempts. The item itself is left in the outbox for manual inspection.
+func (br *OutboxBroadcaster) checkAttempts(ctx context.Context, id string, attempts int) {
+
+	if br.options.MaxAttempts == 0 || attempts != br.options.MaxAttempts {
+		return
+	}
+
+	br.logf("Outbox item %s has reached the maximum number of delivery attempts and will not be retried automatically\n", id)
+	recordDropped(ctx, DROPPED_OUTBOX, 1)
+}
+
+// backoff returns the delay before the next delivery attempt after 'attempts' failed attempts.
+func (br *OutboxBroadcaster) backoff(attempts int) time.Duration {
+	return outboxBackoff(attempts, br.options.Backoff, br.options.MaxBackoff)
+}
+
+// outboxBackoff returns 'base' doubled for each of 'attempts' failed attempts after the first, up to 'max'.
+func outboxBackoff(attempts int, base time.Duration, max time.Duration) time.Duration {
+
+	d := base
+
+	for i := 1; i < attempts; i++ {
+
+		d = d * 2
+
+		if d >= max {
+			return max
+		}
+	}
+
+	if d > max {
+		return max
+	}
+
+	return d
+}
@@ -0,0 +1,315 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-uid"
+	"github.com/tidwall/gjson"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Strategies for posting the parts of messages which are too long to send as a single Slack message.
+const (
+	// OVERFLOW_MESSAGES posts each additional part as a follow-up message in the channel.
+	OVERFLOW_MESSAGES string = "messages"
+	// OVERFLOW_THREAD posts each additional part as a reply in the thread of the first part.
+	OVERFLOW_THREAD string = "thread"
+)
+
+// payload is a single Slack API request that will be made in order to broadcast a message.
+type payload struct {
+	// The name of the Slack API method to call.
+	method string
+	// The (form) arguments to pass to the Slack API method.
+	args url.Values
+	// An optional file to upload along with 'args'.
+	file *payloadFile
+	// Signals that the payload should be posted as a reply in the thread of the first payload.
+	thread bool
+}
+
+// payloadFile is a file to upload as part of a `payload`.
+type payloadFile struct {
+	name         string
+	content_type string
+	body         []byte
+}
+
+// payloads returns the list of Slack API requests to make in order to broadcast 'msg'. If 'separate_text' is true
+// the text of a message with images is posted as its own message rather than as the comment for the first image.
+func (br *SlackBroadcaster) payloads(ctx context.Context, msg *broadcaster.Message, separate_text bool) ([]*payload, error) {
+
+	title, err := br.formatText(ctx, msg.Title)
+
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := br.formatText(ctx, msg.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, texts := splitMessage(title, body, br.max_length)
+
+	identity := br.identity
+
+	ctx_identity, ok := identityFromContext(ctx)
+
+	if ok {
+
+		identity = identity.merge(ctx_identity)
+
+		err := identity.Validate()
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid identity, %w", err)
+		}
+	}
+
+	payloads := make([]*payload, 0)
+
+	if br.overflow == OVERFLOW_SNIPPET && len(chunks) > 1 {
+
+		summary := snippetSummary(title, body, br.snippet_lines, br.max_length)
+
+		p, err := br.snippetPayload(msg.Body, summary)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive snippet, %w", err)
+		}
+
+		payloads = append(payloads, p)
+
+		chunks = []string{}
+		texts = []string{}
+	}
+
+	for idx, im := range msg.Images {
+
+		im_body, err := br.encodeImage(ctx, im)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encode image %d, %w", idx, err)
+		}
+
+		args := url.Values{}
+		args.Set("channels", br.channel)
+
+		if idx == 0 && len(texts) > 0 && texts[0] != "" && !separate_text {
+			args.Set("initial_comment", texts[0])
+		}
+
+		p := &payload{
+			method: "files.upload",
+			args:   args,
+			file: &payloadFile{
+				name:         "upload.png",
+				content_type: "image/png",
+				body:         im_body,
+			},
+		}
+
+		payloads = append(payloads, p)
+	}
+
+	for idx, text := range texts {
+
+		if len(msg.Images) > 0 && idx == 0 && (!separate_text || text == "") {
+			continue
+		}
+
+		args := url.Values{}
+		args.Set("channel", br.channel)
+		args.Set("text", text)
+
+		identity.apply(args)
+
+		block_title := ""
+
+		if idx == 0 {
+			block_title = msg.Title
+		}
+
+		// Parts of a title which was too long to share a message with the body have no blocks
+
+		if br.blocks && (block_title != "" || chunks[idx] != "") {
+
+			blocks, err := renderBlocks(block_title, splitText(chunks[idx], blocks_max_section_length))
+
+			if err != nil {
+				return nil, fmt.Errorf("Failed to render blocks, %w", err)
+			}
+
+			args.Set("blocks", blocks)
+		}
+
+		p := &payload{
+			method: "chat.postMessage",
+			args:   args,
+			thread: len(payloads) > 0 && br.overflow == OVERFLOW_THREAD,
+		}
+
+		payloads = append(payloads, p)
+	}
+
+	return payloads, nil
+}
+
+// splitMessage splits 'title' and 'body' in to the texts of one or more messages none of which are longer than
+// 'max_length' characters. It returns the chunks of 'body' in each message and the full text of each message. The
+// title is prepended to the first chunk, which is split to leave room for it, unless the title is longer than half of
+// 'max_length' in which case it is posted as its own message (or messages) with an empty chunk.
+func splitMessage(title string, body string, max_length int) ([]string, []string) {
+
+	title_len := utf8.RuneCountInString(title)
+
+	if title_len == 0 {
+		chunks := splitText(body, max_length)
+		return chunks, append([]string{}, chunks...)
+	}
+
+	first_limit := max_length - title_len - 1
+
+	if utf8.RuneCountInString(body) <= first_limit || first_limit >= max_length/2 {
+
+		chunks := splitTextFirst(body, first_limit, max_length)
+
+		texts := make([]string, len(chunks))
+		copy(texts, chunks)
+
+		texts[0] = strings.TrimSpace(fmt.Sprintf("%s %s", title, chunks[0]))
+		return chunks, texts
+	}
+
+	texts := splitText(title, max_length)
+	chunks := make([]string, len(texts))
+
+	if strings.TrimSpace(body) != "" {
+		body_chunks := splitText(body, max_length)
+		chunks = append(chunks, body_chunks...)
+		texts = append(texts, body_chunks...)
+	}
+
+	return chunks, texts
+}
+
+// send executes each of 'payloads' in order and returns a `uid.UID` referencing all of the messages that
+// were posted.
+func (br *SlackBroadcaster) send(ctx context.Context, payloads []*payload) (uid.UID, error) {
+
+	ids := make([]uid.UID, 0)
+	thread_ts := ""
+	warned := false
+
+	for idx, p := range payloads {
+
+		if p.thread && thread_ts != "" {
+			p.args.Set("thread_ts", thread_ts)
+		}
+
+		// This happens if the first part was a file upload whose message could not be determined
+
+		if p.thread && thread_ts == "" && !warned {
+			br.logf("Unable to determine the thread of the first part of the message, posting parts %d to %d in the channel instead\n", idx+1, len(payloads))
+			warned = true
+		}
+
+		id, err := br.sendPayload(ctx, p)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to send part %d of %d, %w", idx+1, len(payloads), err)
+		}
+
+		if idx == 0 {
+
+			switch msg_id := id.(type) {
+			case *MessageUID:
+				thread_ts = msg_id.Timestamp()
+			case *DryRunUID:
+				thread_ts = msg_id.Timestamp()
+			}
+		}
+
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 1 {
+		return ids[0], nil
+	}
+
+	return uid.NewMultiUID(ctx, ids...), nil
+}
+
+func (br *SlackBroadcaster) sendPayload(ctx context.Context, p *payload) (uid.UID, error) {
+
+	if br.dry_run {
+		return br.dryRun(ctx, p)
+	}
+
+	if p.file != nil {
+
+		r := bytes.NewReader(p.file.body)
+
+		body, err := br.uploadReader(ctx, r, p.file.name, &p.args)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to upload file, %w", br.teamError(ctx, err))
+		}
+
+		return br.fileUID(ctx, body)
+	}
+
+	body, err := br.api(ctx, p.method, p.args)
+
+	if err != nil {
+		return nil, br.teamError(ctx, err)
+	}
+
+	switch p.method {
+	case "chat.postEphemeral":
+		ts := gjson.GetBytes(body, "message_ts").String()
+		return NewEphemeralMessageUID(ctx, p.args.Get("channel"), p.args.Get("user"), ts)
+	case "chat.scheduleMessage":
+		channel := gjson.GetBytes(body, "channel").String()
+		id := gjson.GetBytes(body, "scheduled_message_id").String()
+		post_at := time.Unix(gjson.GetBytes(body, "post_at").Int(), 0)
+		return NewScheduledMessageUID(ctx, channel, id, post_at)
+	}
+
+	channel := gjson.GetBytes(body, "channel").String()
+	ts := gjson.GetBytes(body, "ts").String()
+
+	return NewMessageUID(ctx, channel, ts)
+}
+
+// fileUID returns a `MessageUID` for the message that a file was shared in, derived from the body of a
+// files.upload API response. If the message can not be determined it returns a timestamp-based UID.
+func (br *SlackBroadcaster) fileUID(ctx context.Context, body []byte) (uid.UID, error) {
+
+	for _, visibility := range []string{"public", "private"} {
+
+		shares := gjson.GetBytes(body, "file.shares."+visibility)
+
+		if !shares.Exists() {
+			continue
+		}
+
+		for channel, messages := range shares.Map() {
+
+			ts := messages.Get("0.ts").String()
+
+			if ts != "" {
+				return NewMessageUID(ctx, channel, ts)
+			}
+		}
+	}
+
+	return br.uid(ctx)
+}
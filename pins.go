@@ -0,0 +1,91 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-uid"
+	"net/url"
+	"strings"
+)
+
+// The maximum length of a channel's topic or purpose.
+const max_topic_length int = 250
+
+// Pin pins the message identified by 'id' to its channel using the pins.add API method. If 'id' references
+// multiple messages the first message is pinned.
+func (br *SlackBroadcaster) Pin(ctx context.Context, id uid.UID) error {
+	return br.updatePin(ctx, "pins.add", id)
+}
+
+// Unpin unpins the message identified by 'id' from its channel using the pins.remove API method.
+func (br *SlackBroadcaster) Unpin(ctx context.Context, id uid.UID) error {
+	return br.updatePin(ctx, "pins.remove", id)
+}
+
+func (br *SlackBroadcaster) updatePin(ctx context.Context, method string, id uid.UID) error {
+
+	channel, ts, err := messageRef(ctx, id)
+
+	if err != nil {
+		return fmt.Errorf("Failed to derive message from UID, %w", err)
+	}
+
+	args := url.Values{}
+	args.Set("channel", channel)
+	args.Set("timestamp", ts)
+
+	_, err = br.api(ctx, method, args)
+
+	if err != nil {
+		return fmt.Errorf("Failed to update pin, %w", err)
+	}
+
+	return nil
+}
+
+// SetTopic sets the topic of the channel associated with 'br' to 'topic' using the conversations.setTopic
+// API method. Topics longer than 250 characters are truncated.
+func (br *SlackBroadcaster) SetTopic(ctx context.Context, topic string) error {
+	return br.setChannelProperty(ctx, "conversations.setTopic", "topic", topic)
+}
+
+// SetPurpose sets the purpose of the channel associated with 'br' to 'purpose' using the conversations.setPurpose
+// API method. Purposes longer than 250 characters are truncated.
+func (br *SlackBroadcaster) SetPurpose(ctx context.Context, purpose string) error {
+	return br.setChannelProperty(ctx, "conversations.setPurpose", "purpose", purpose)
+}
+
+// SetTopicFromMessage sets the topic of the channel associated with 'br' to the title of 'msg', or its body
+// if the title is empty.
+func (br *SlackBroadcaster) SetTopicFromMessage(ctx context.Context, msg *broadcaster.Message) error {
+
+	topic := strings.TrimSpace(msg.Title)
+
+	if topic == "" {
+		topic = strings.TrimSpace(msg.Body)
+	}
+
+	return br.SetTopic(ctx, topic)
+}
+
+func (br *SlackBroadcaster) setChannelProperty(ctx context.Context, method string, key string, value string) error {
+
+	channel, err := br.channelID(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	args := url.Values{}
+	args.Set("channel", channel)
+	args.Set(key, truncateString(value, max_topic_length))
+
+	_, err = br.api(ctx, method, args)
+
+	if err != nil {
+		return fmt.Errorf("Failed to set channel %s, %w", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package slack
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Valid values for the `PostOptions.Parse` property.
+const (
+	// PARSE_NONE tells Slack not to parse message text for links, mentions or channel names.
+	PARSE_NONE string = "none"
+	// PARSE_FULL tells Slack to parse message text for links, mentions and channel names.
+	PARSE_FULL string = "full"
+)
+
+// PostOptions defines optional flags controlling how Slack formats and unfurls posted messages. Properties
+// which are nil (or empty) are not sent to Slack, in which case Slack's own defaults apply.
+type PostOptions struct {
+	// UnfurlLinks enables or disables unfurling of text-based content.
+	UnfurlLinks *bool `json:"unfurl_links,omitempty"`
+	// UnfurlMedia enables or disables unfurling of media content.
+	UnfurlMedia *bool `json:"unfurl_media,omitempty"`
+	// LinkNames enables or disables finding and linking user groups.
+	LinkNames *bool `json:"link_names,omitempty"`
+	// Mrkdwn enables or disables Slack markup parsing.
+	Mrkdwn *bool `json:"mrkdwn,omitempty"`
+	// Parse changes how messages are treated. Valid options are "none" and "full".
+	Parse string `json:"parse,omitempty"`
+}
+
+// The post options supported by each Slack API method used to post messages. The files.upload
+// API method does not support any of them.
+var post_options_methods = map[string][]string{
+	"chat.postMessage":     {"unfurl_links", "unfurl_media", "link_names", "mrkdwn", "parse"},
+	"chat.scheduleMessage": {"unfurl_links", "unfurl_media", "link_names", "parse"},
+	"chat.postEphemeral":   {"link_names", "parse"},
+}
+
+// Validate ensures that the properties of 'opts' are valid.
+func (opts *PostOptions) Validate() error {
+
+	switch opts.Parse {
+	case "", PARSE_NONE, PARSE_FULL:
+		// pass
+	default:
+		return fmt.Errorf("Invalid parse option '%s'", opts.Parse)
+	}
+
+	return nil
+}
+
+// checkMentions ensures that 'opts' do not ask Slack to link bare "@handle" text, using the `link_names` or `parse=full`
+// options, unless both user and user group mentions are allowed by 'policy'. Otherwise those options would bypass 'policy'
+// since only bare `@here`, `@channel` and `@everyone` strings are neutralized when message text is escaped.
+func (opts *PostOptions) checkMentions(policy *MentionPolicy) error {
+
+	if policy.Allows(MENTION_USERS) && policy.Allows(MENTION_GROUPS) {
+		return nil
+	}
+
+	if opts.LinkNames != nil && *opts.LinkNames {
+		return fmt.Errorf("The link_names option requires that user and user group mentions are allowed")
+	}
+
+	if opts.Parse == PARSE_FULL {
+		return fmt.Errorf("The parse=full option requires that user and user group mentions are allowed")
+	}
+
+	return nil
+}
+
+// merge returns a new `PostOptions` whose properties are those of 'opts' overridden by any non-nil
+// (or non-empty) properties of 'other'.
+func (opts *PostOptions) merge(other *PostOptions) *PostOptions {
+
+	merged := &PostOptions{}
+
+	if opts != nil {
+		*merged = *opts
+	}
+
+	if other == nil {
+		return merged
+	}
+
+	if other.UnfurlLinks != nil {
+		merged.UnfurlLinks = other.UnfurlLinks
+	}
+
+	if other.UnfurlMedia != nil {
+		merged.UnfurlMedia = other.UnfurlMedia
+	}
+
+	if other.LinkNames != nil {
+		merged.LinkNames = other.LinkNames
+	}
+
+	if other.Mrkdwn != nil {
+		merged.Mrkdwn = other.Mrkdwn
+	}
+
+	if other.Parse != "" {
+		merged.Parse = other.Parse
+	}
+
+	return merged
+}
+
+// apply assigns the properties of 'opts' supported by 'method' to 'args'.
+func (opts *PostOptions) apply(method string, args url.Values) {
+
+	values := map[string]string{}
+
+	setBool := func(key string, v *bool) {
+
+		if v != nil {
+			values[key] = strconv.FormatBool(*v)
+		}
+	}
+
+	setBool("unfurl_links", opts.UnfurlLinks)
+	setBool("unfurl_media", opts.UnfurlMedia)
+	setBool("link_names", opts.LinkNames)
+	setBool("mrkdwn", opts.Mrkdwn)
+
+	if opts.Parse != "" {
+		values["parse"] = opts.Parse
+	}
+
+	for _, key := range post_options_methods[method] {
+
+		v, ok := values[key]
+
+		if ok {
+			args.Set(key, v)
+		}
+	}
+}
+
+// newPostOptionsFromQuery returns a new `PostOptions` derived from the "unfurl-links", "unfurl-media",
+// "link-names", "mrkdwn" and "parse" parameters in 'q'.
+func newPostOptionsFromQuery(q url.Values) (*PostOptions, error) {
+
+	opts := &PostOptions{
+		Parse: q.Get("parse"),
+	}
+
+	params := map[string]**bool{
+		"unfurl-links": &opts.UnfurlLinks,
+		"unfurl-media": &opts.UnfurlMedia,
+		"link-names":   &opts.LinkNames,
+		"mrkdwn":       &opts.Mrkdwn,
+	}
+
+	for name, ptr := range params {
+
+		if !q.Has(name) {
+			continue
+		}
+
+		v, err := strconv.ParseBool(q.Get(name))
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?%s= parameter, %w", name, err)
+		}
+
+		*ptr = &v
+	}
+
+	err := opts.Validate()
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid ?parse= parameter, %w", err)
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,439 @@
+package slack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Slack Web API rate limit tiers, expressed as the number of requests allowed per minute.
+// See https://api.slack.com/docs/rate-limits
+const (
+	TIER_1 int = 1
+	TIER_2 int = 20
+	TIER_3 int = 50
+	TIER_4 int = 100
+	// TIER_SPECIAL signals that a method is only subject to the per-channel limit.
+	TIER_SPECIAL int = 0
+)
+
+// method_tiers maps Slack API methods used by this package to their rate limit tier. Methods which
+// are not listed are assumed to be TIER_3.
+var method_tiers = map[string]int{
+	"auth.test":                   TIER_4,
+	"chat.deleteScheduledMessage": TIER_3,
+	"chat.postEphemeral":          TIER_SPECIAL,
+	"chat.postMessage":            TIER_SPECIAL,
+	"chat.scheduleMessage":        TIER_3,
+	"chat.scheduledMessages.list": TIER_3,
+	"conversations.history":       TIER_3,
+	"conversations.list":          TIER_2,
+	"conversations.setPurpose":    TIER_2,
+	"conversations.setTopic":      TIER_2,
+	"files.upload":                TIER_2,
+	"pins.add":                    TIER_2,
+	"pins.remove":                 TIER_2,
+	"reactions.add":               TIER_3,
+	"reactions.remove":            TIER_2,
+	"usergroups.list":             TIER_2,
+	"users.list":                  TIER_2,
+}
+
+// channel_methods are the API methods which post messages to a channel and are subject to the per-channel limit.
+var channel_methods = map[string]bool{
+	"chat.postEphemeral":   true,
+	"chat.postMessage":     true,
+	"chat.scheduleMessage": true,
+	"files.upload":         true,
+}
+
+// RateLimits defines the limits enforced by a `RateLimiter`.
+type RateLimits struct {
+	// The number of messages per second which may be posted to a single channel.
+	ChannelRate float64
+	// The number of messages which may be posted to a single channel in a burst before ChannelRate applies.
+	ChannelBurst int
+	// Tiers maps Slack API methods to the number of requests per minute allowed for that method, or TIER_SPECIAL
+	// if a method is only subject to the per-channel limit. Methods which are not listed use the tiers published by Slack.
+	Tiers map[string]int
+}
+
+// DefaultRateLimits returns the `RateLimits` published by Slack: one message per second per channel
+// and the published tier for each API method.
+func DefaultRateLimits() *RateLimits {
+
+	l := &RateLimits{
+		ChannelRate:  1.0,
+		ChannelBurst: 1,
+	}
+
+	return l
+}
+
+// String returns a string representation of 'l'.
+func (l *RateLimits) String() string {
+
+	tiers := make([]string, 0)
+
+	for m, t := range l.Tiers {
+		tiers = append(tiers, fmt.Sprintf("%s=%d", m, t))
+	}
+
+	sort.Strings(tiers)
+
+	return fmt.Sprintf("%f/%d/%s", l.ChannelRate, l.ChannelBurst, strings.Join(tiers, ","))
+}
+
+// tier returns the number of requests per minute allowed for 'method'.
+func (l *RateLimits) tier(method string) int {
+
+	t, ok := l.Tiers[method]
+
+	if ok {
+		return t
+	}
+
+	t, ok = method_tiers[method]
+
+	if ok {
+		return t
+	}
+
+	return TIER_3
+}
+
+// RateLimitStats describes the amount of time callers have spent waiting for a `RateLimiter`.
+type RateLimitStats struct {
+	// The number of requests which have been checked against the rate limiter.
+	Requests int64
+	// The number of requests which had to wait before being sent.
+	Waits int64
+	// The total amount of time requests have waited.
+	TotalWait time.Duration
+	// The longest amount of time a single request has waited.
+	MaxWait time.Duration
+}
+
+// tokenBucket is a token bucket which refills at 'rate' tokens per second up to 'burst' tokens.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// reserve takes a token from 'b' and returns the amount of time the caller must wait before using it. Tokens
+// may be borrowed against future refills so that concurrent callers are queued rather than rejected.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+
+	wait := time.Duration(0)
+
+	if now.Before(b.last) {
+
+		// The bucket has been blocked until some time in the future
+
+		wait = b.last.Sub(now)
+
+	} else {
+
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+
+		b.last = now
+	}
+
+	b.tokens -= 1
+
+	if b.tokens < 0 {
+		wait += time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+
+	return wait
+}
+
+// block prevents 'b' from issuing tokens until 'until'.
+func (b *tokenBucket) block(until time.Time) {
+
+	if until.After(b.last) {
+		b.last = until
+		b.tokens = 0
+	}
+}
+
+// RateLimiter is a client-side token bucket rate limiter for Slack API requests. Requests are limited per API
+// token and method, according to the method's rate limit tier, and requests which post messages are also
+// limited per API token and channel. A single `RateLimiter` is meant to be shared by every `SlackBroadcaster`
+// in a process.
+type RateLimiter struct {
+	limits  *RateLimits
+	mu      *sync.Mutex
+	buckets map[string]*tokenBucket
+	stats   *RateLimitStats
+}
+
+var shared_limiters = make(map[string]*RateLimiter)
+
+var shared_limiters_mu = new(sync.Mutex)
+
+// NewRateLimiter returns a new `RateLimiter` instance enforcing 'limits'.
+func NewRateLimiter(limits *RateLimits) (*RateLimiter, error) {
+
+	if limits.ChannelRate <= 0 {
+		return nil, fmt.Errorf("Channel rate must be greater than zero")
+	}
+
+	if limits.ChannelBurst < 1 {
+		return nil, fmt.Errorf("Channel burst must be at least one")
+	}
+
+	for m, t := range limits.Tiers {
+
+		if t < 0 {
+			return nil, fmt.Errorf("Invalid tier for %s, must not be negative", m)
+		}
+	}
+
+	l := &RateLimiter{
+		limits:  limits,
+		mu:      new(sync.Mutex),
+		buckets: make(map[string]*tokenBucket),
+		stats:   &RateLimitStats{},
+	}
+
+	return l, nil
+}
+
+// SharedRateLimiter returns the process-wide `RateLimiter` instance enforcing 'limits', creating it if necessary.
+// All the callers which pass equivalent limits share the same instance.
+func SharedRateLimiter(limits *RateLimits) (*RateLimiter, error) {
+
+	shared_limiters_mu.Lock()
+	defer shared_limiters_mu.Unlock()
+
+	key := limits.String()
+
+	l, ok := shared_limiters[key]
+
+	if ok {
+		return l, nil
+	}
+
+	l, err := NewRateLimiter(limits)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shared_limiters[key] = l
+	return l, nil
+}
+
+// Wait blocks until a request to the API 'method' using 'token', and posting to 'channel' if 'method' posts
+// messages, is allowed or 'ctx' is cancelled. It returns the amount of time spent waiting.
+func (l *RateLimiter) Wait(ctx context.Context, token string, channel string, method string) (time.Duration, error) {
+
+	now := time.Now()
+
+	l.mu.Lock()
+
+	d := time.Duration(0)
+
+	tier := l.limits.tier(method)
+
+	if tier != TIER_SPECIAL {
+		d = l.methodBucket(token, method, tier, now).reserve(now)
+	}
+
+	if channel_methods[method] && channel != "" {
+
+		channel_d := l.bucket(l.channelKey(token, channel), l.limits.ChannelRate, l.limits.ChannelBurst, now).reserve(now)
+
+		if channel_d > d {
+			d = channel_d
+		}
+	}
+
+	l.stats.Requests += 1
+
+	if d > 0 {
+
+		l.stats.Waits += 1
+		l.stats.TotalWait += d
+
+		if d > l.stats.MaxWait {
+			l.stats.MaxWait = d
+		}
+	}
+
+	l.mu.Unlock()
+
+	if d <= 0 {
+		return 0, nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return time.Since(now), ctx.Err()
+	case <-timer.C:
+		return d, nil
+	}
+}
+
+// Block prevents any further requests to the API 'method' using 'token' until 'd' has elapsed. It is
+// used when Slack responds with a 429 status code so that every caller sharing 'l' backs off.
+func (l *RateLimiter) Block(token string, method string, d time.Duration) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(d)
+
+	tier := l.limits.tier(method)
+
+	if tier == TIER_SPECIAL {
+
+		// Methods with special limits are only limited per channel and the response does not say which
+		// channel was affected so block every channel bucket for the token
+
+		prefix := hashToken(token) + "#channel:"
+
+		for k, b := range l.buckets {
+
+			if strings.HasPrefix(k, prefix) {
+				b.block(until)
+			}
+		}
+
+		return
+	}
+
+	l.methodBucket(token, method, tier, time.Now()).block(until)
+}
+
+// Stats returns a copy of the wait statistics for 'l'.
+func (l *RateLimiter) Stats() RateLimitStats {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return *l.stats
+}
+
+// bucket returns the bucket for 'key', creating it (full) at 'now' if necessary. Callers must hold the lock for 'l'.
+func (l *RateLimiter) bucket(key string, rate float64, burst int, now time.Time) *tokenBucket {
+
+	b, ok := l.buckets[key]
+
+	if !ok {
+
+		b = &tokenBucket{
+			rate:   rate,
+			burst:  float64(burst),
+			tokens: float64(burst),
+			last:   now,
+		}
+
+		l.buckets[key] = b
+	}
+
+	return b
+}
+
+// methodBucket returns the bucket for requests to 'method' using 'token', which allows 'tier' requests per minute
+// and bursts of up to a minute's worth of requests. Callers must hold the lock for 'l'.
+func (l *RateLimiter) methodBucket(token string, method string, tier int, now time.Time) *tokenBucket {
+	key := fmt.Sprintf("%s#method:%s", hashToken(token), method)
+	return l.bucket(key, float64(tier)/60.0, tier, now)
+}
+
+func (l *RateLimiter) channelKey(token string, channel string) string {
+	channel = strings.ToLower(strings.TrimPrefix(channel, "#"))
+	return fmt.Sprintf("%s#channel:%s", hashToken(token), channel)
+}
+
+// hashToken returns a hash of 'token' so that API tokens are not kept in memory any longer than necessary.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// retryAfter returns the duration specified by the Retry-After header of 'rsp', or one second if it is missing.
+func retryAfter(rsp *http.Response) time.Duration {
+
+	v, err := strconv.Atoi(rsp.Header.Get("Retry-After"))
+
+	if err != nil || v < 1 {
+		return time.Second
+	}
+
+	return time.Duration(v) * time.Second
+}
+
+// apiMethod returns the name of the Slack API method called by 'req'.
+func apiMethod(req *http.Request) string {
+	return strings.TrimPrefix(req.URL.Path, "/api/")
+}
+
+// newRateLimiterFromQuery returns the shared `RateLimiter` instance for the "rate-limit", "channel-rate" and "channel-burst"
+// parameters in 'q', or nil if rate limiting is disabled.
+func newRateLimiterFromQuery(q url.Values) (*RateLimiter, error) {
+
+	if q.Has("rate-limit") {
+
+		v, err := strconv.ParseBool(q.Get("rate-limit"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?rate-limit= parameter, %w", err)
+		}
+
+		if !v {
+			return nil, nil
+		}
+	}
+
+	limits := DefaultRateLimits()
+
+	if q.Has("channel-rate") {
+
+		v, err := strconv.ParseFloat(q.Get("channel-rate"), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?channel-rate= parameter, %w", err)
+		}
+
+		limits.ChannelRate = v
+	}
+
+	if q.Has("channel-burst") {
+
+		v, err := strconv.Atoi(q.Get("channel-burst"))
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid ?channel-burst= parameter, %w", err)
+		}
+
+		limits.ChannelBurst = v
+	}
+
+	l, err := SharedRateLimiter(limits)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid rate limit parameters, %w", err)
+	}
+
+	return l, nil
+}
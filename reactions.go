@@ -0,0 +1,127 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-uid"
+	"net/url"
+	"strings"
+)
+
+// messageRef returns the channel ID and timestamp of the message identified by 'id'. If 'id' references
+// multiple messages (for example the parts of a message which was split) the first message is used.
+func messageRef(ctx context.Context, id uid.UID) (string, string, error) {
+
+	switch u := id.(type) {
+	case *MessageUID:
+		return u.Channel(), u.Timestamp(), nil
+	case *uid.MultiUID:
+
+		ids, ok := u.Value().([]uid.UID)
+
+		if !ok || len(ids) == 0 {
+			return "", "", fmt.Errorf("Empty multi UID")
+		}
+
+		return messageRef(ctx, ids[0])
+
+	case *DuplicateUID:
+
+		if u.ref == "" {
+			return "", "", fmt.Errorf("Duplicate message can not be referenced")
+		}
+
+		m, err := ParseMessageUID(ctx, u.ref)
+
+		if err != nil {
+			return "", "", err
+		}
+
+		return messageRef(ctx, m)
+
+	case *DigestUID:
+
+		digest_id, ok := u.Resolved()
+
+		if !ok {
+			return "", "", fmt.Errorf("Digest has not been posted")
+		}
+
+		return messageRef(ctx, digest_id)
+
+	case *EphemeralMessageUID:
+		return "", "", fmt.Errorf("Ephemeral messages can not be referenced")
+	case *ScheduledMessageUID:
+		return "", "", fmt.Errorf("Scheduled messages can not be referenced until they have been posted")
+	}
+
+	m, err := ParseMessageUID(ctx, id.String())
+
+	if err != nil {
+		return "", "", fmt.Errorf("Unsupported UID, %w", err)
+	}
+
+	msg_id := m.(*MessageUID)
+	return msg_id.Channel(), msg_id.Timestamp(), nil
+}
+
+// AddReaction adds the emoji reaction 'name' (for example "white_check_mark") to the message identified by 'id'
+// using the reactions.add API method.
+func (br *SlackBroadcaster) AddReaction(ctx context.Context, id uid.UID, name string) error {
+	return br.reaction(ctx, "reactions.add", id, name)
+}
+
+// RemoveReaction removes the emoji reaction 'name' from the message identified by 'id' using the reactions.remove
+// API method.
+func (br *SlackBroadcaster) RemoveReaction(ctx context.Context, id uid.UID, name string) error {
+	return br.reaction(ctx, "reactions.remove", id, name)
+}
+
+// SwapReaction replaces the emoji reaction 'from' with 'to' on the message identified by 'id', for example to
+// update a "build started" message from "hourglass" to "white_check_mark". The new reaction is added before
+// the old one is removed. It is not an error if the message already has the 'to' reaction or does not have
+// the 'from' reaction.
+func (br *SlackBroadcaster) SwapReaction(ctx context.Context, id uid.UID, from string, to string) error {
+
+	err := br.AddReaction(ctx, id, to)
+
+	if err != nil && !IsAPIError(err, "already_reacted") {
+		return err
+	}
+
+	err = br.RemoveReaction(ctx, id, from)
+
+	if err != nil && !IsAPIError(err, "no_reaction") {
+		return err
+	}
+
+	return nil
+}
+
+func (br *SlackBroadcaster) reaction(ctx context.Context, method string, id uid.UID, name string) error {
+
+	channel, ts, err := messageRef(ctx, id)
+
+	if err != nil {
+		return fmt.Errorf("Failed to derive message from UID, %w", err)
+	}
+
+	name = strings.Trim(name, ":")
+
+	if name == "" {
+		return fmt.Errorf("Missing reaction name")
+	}
+
+	args := url.Values{}
+	args.Set("channel", channel)
+	args.Set("timestamp", ts)
+	args.Set("name", name)
+
+	_, err = br.api(ctx, method, args)
+
+	if err != nil {
+		return fmt.Errorf("Failed to update reaction '%s', %w", name, err)
+	}
+
+	return nil
+}
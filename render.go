@@ -0,0 +1,144 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+)
+
+// render_json_args are the API arguments whose values are JSON encoded and are rendered as JSON, rather than
+// as strings.
+var render_json_args = map[string]bool{
+	"attachments": true,
+	"blocks":      true,
+	"metadata":    true,
+}
+
+// RenderedRequest describes a single Slack API request made to broadcast a message.
+type RenderedRequest struct {
+	// The name of the Slack API method.
+	Method string `json:"method"`
+	// The URL of the Slack API method.
+	URL string `json:"url"`
+	// The form arguments sent to the Slack API method. Arguments whose values are JSON encoded, like "blocks"
+	// and "metadata", are `json.RawMessage` values. All others are strings.
+	Args map[string]any `json:"args"`
+	// The file uploaded with the request, if any.
+	File *RenderedFile `json:"file,omitempty"`
+	// Thread signals that the request is posted as a reply in the thread of the first request. The "thread_ts"
+	// argument is only assigned once the first request has been sent.
+	Thread bool `json:"thread,omitempty"`
+}
+
+// RenderedFile describes a file uploaded by a `RenderedRequest` without including its contents.
+type RenderedFile struct {
+	// The name of the file.
+	Name string `json:"name"`
+	// The MIME type of the file.
+	ContentType string `json:"content_type"`
+	// The size of the file in bytes.
+	Size int `json:"size"`
+}
+
+// RenderPayload returns the list of Slack API requests that a `SlackBroadcaster` configured by 'opts' would make,
+// in order, to broadcast 'msg'. Per-message options stored in 'ctx' are applied. No API calls are made so
+// 'opts.Token' is not required and 'opts.ResolveMentions' is not supported. The output is deterministic for a
+// given message and options, except for scheduled messages whose 'opts.PostAt' setting is relative to the
+// current time, and is suitable for snapshot tests. For example:
+//
+//	opts := slack.DefaultOptions("general", "")
+//	opts.Blocks = true
+//
+//	reqs, _ := slack.RenderPayload(ctx, msg, opts)
+//	body, _ := json.MarshalIndent(reqs, "", "  ")
+func RenderPayload(ctx context.Context, msg *broadcaster.Message, opts *Options) ([]*RenderedRequest, error) {
+
+	slack_msg := &Message{
+		Message: msg,
+	}
+
+	return RenderSlackPayload(ctx, slack_msg, opts)
+}
+
+// RenderSlackPayload returns the list of Slack API requests that a `SlackBroadcaster` configured by 'opts' would
+// make, in order, to broadcast 'msg' using the `BroadcastSlackMessage` method. It is otherwise identical to
+// the `RenderPayload` method.
+func RenderSlackPayload(ctx context.Context, msg *Message, opts *Options) ([]*RenderedRequest, error) {
+
+	if opts == nil {
+		return nil, fmt.Errorf("Missing options")
+	}
+
+	if msg == nil || msg.Message == nil {
+		return nil, fmt.Errorf("Missing message")
+	}
+
+	o := *opts
+
+	if o.ResolveMentions {
+		return nil, fmt.Errorf("Resolving mentions is not supported when rendering payloads")
+	}
+
+	err := o.validate()
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid options, %w", err)
+	}
+
+	br, err := newSlackBroadcaster(ctx, &o)
+
+	if err != nil {
+		return nil, err
+	}
+
+	payloads, _, _, err := br.preparePayloads(ctx, msg)
+
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]*RenderedRequest, len(payloads))
+
+	for idx, p := range payloads {
+		reqs[idx] = renderPayload(p)
+	}
+
+	return reqs, nil
+}
+
+// renderPayload returns a `RenderedRequest` describing the API request for 'p'.
+func renderPayload(p *payload) *RenderedRequest {
+
+	r := &RenderedRequest{
+		Method: p.method,
+		URL:    SLACK_API_ENDPOINT + p.method,
+		Args:   make(map[string]any),
+		Thread: p.thread,
+	}
+
+	for k, v := range p.args {
+
+		if len(v) == 0 {
+			continue
+		}
+
+		if render_json_args[k] && json.Valid([]byte(v[0])) {
+			r.Args[k] = json.RawMessage(v[0])
+			continue
+		}
+
+		r.Args[k] = v[0]
+	}
+
+	if p.file != nil {
+
+		r.File = &RenderedFile{
+			Name:        p.file.name,
+			ContentType: p.file.content_type,
+			Size:        len(p.file.body),
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,122 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/aaronland/go-broadcaster"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Update the golden files in testdata")
+
+func TestRenderPayload(t *testing.T) {
+
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		msg  *broadcaster.Message
+		opts func(*Options)
+	}{
+		"text": {
+			msg: &broadcaster.Message{
+				Title: "Deploy finished",
+				Body:  "Version 1.2.3 is live.",
+			},
+		},
+		"blocks": {
+			msg: &broadcaster.Message{
+				Title: "Deploy finished",
+				Body:  "Version 1.2.3 is live.\n\nSee the *changelog* for details.",
+			},
+			opts: func(o *Options) {
+				o.Blocks = true
+			},
+		},
+		"split": {
+			msg: &broadcaster.Message{
+				Title: "Build log",
+				Body:  strings.Repeat("word ", 20) + "\n\n```\n" + strings.Repeat("code line\n", 6) + "```",
+			},
+			opts: func(o *Options) {
+				o.MaxLength = 50
+				o.Overflow = OVERFLOW_THREAD
+			},
+		},
+		"split-long-title": {
+			msg: &broadcaster.Message{
+				Title: "A title which is almost as long as the limit",
+				Body:  strings.Repeat("word ", 20) + "\n\n```\n" + strings.Repeat("code line\n", 6) + "```",
+			},
+			opts: func(o *Options) {
+				o.MaxLength = 50
+				o.Overflow = OVERFLOW_THREAD
+			},
+		},
+		"escape": {
+			msg: &broadcaster.Message{
+				Title: "Alert <!channel>",
+				Body:  "Ping <@U0123456789> & <!here>, see <https://example.com|this>.",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			opts := DefaultOptions("C0123456789", "")
+
+			if tc.opts != nil {
+				tc.opts(opts)
+			}
+
+			reqs, err := RenderPayload(ctx, tc.msg, opts)
+
+			if err != nil {
+				t.Fatalf("Failed to render payload, %v", err)
+			}
+
+			// Disable HTML escaping so that the escaping applied to message text is legible in the golden files
+
+			var buf bytes.Buffer
+
+			enc := json.NewEncoder(&buf)
+			enc.SetEscapeHTML(false)
+			enc.SetIndent("", "  ")
+
+			err = enc.Encode(reqs)
+
+			if err != nil {
+				t.Fatalf("Failed to marshal requests, %v", err)
+			}
+
+			got := buf.Bytes()
+
+			path := filepath.Join("testdata", name+".golden")
+
+			if *update {
+
+				err := os.WriteFile(path, got, 0644)
+
+				if err != nil {
+					t.Fatalf("Failed to write %s, %v", path, err)
+				}
+			}
+
+			expected, err := os.ReadFile(path)
+
+			if err != nil {
+				t.Fatalf("Failed to read %s, %v", path, err)
+			}
+
+			if !bytes.Equal(got, expected) {
+				t.Fatalf("Rendered payload does not match %s (run 'go test -update' to update it)\n%s", path, got)
+			}
+		})
+	}
+}
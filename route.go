@@ -0,0 +1,246 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// The name of the route for messages which do not match any rules.
+const ROUTE_DEFAULT string = "default"
+
+// Matches a leading severity tag, like "[ERROR]", in message text.
+var re_severity = regexp.MustCompile(`^\s*\[([A-Za-z]+)\]`)
+
+// Matches a Slack channel name: lowercase letters, numbers, hyphens and underscores.
+var re_channel_name = regexp.MustCompile(`^[\p{Ll}\p{Lo}\p{Nd}_\-]{1,80}$`)
+
+// RouteRules defines an ordered list of rules used to choose the channels that messages are posted to.
+type RouteRules struct {
+	// The rules to evaluate, in order.
+	Rules []*RouteRule `json:"rules"`
+	// The channels to post messages which do not match any rules to.
+	Default []string `json:"default,omitempty"`
+}
+
+// RouteRule defines the conditions a message must match in order to be posted to one or more channels. A rule
+// matches if all of the conditions it defines match. A rule with no conditions matches every message.
+type RouteRule struct {
+	// An optional name for the rule. If empty rules are named by their (1-based) position.
+	Name string `json:"name,omitempty"`
+	// A regular expression which must match the message title or body.
+	Pattern string `json:"pattern,omitempty"`
+	// A string which the message title, or the body if there is no title, must start with.
+	Prefix string `json:"prefix,omitempty"`
+	// One or more severity tags, like "ERROR", one of which must appear in square brackets at the start of the
+	// message title or body. Tags are compared case-insensitively.
+	Severity []string `json:"severity,omitempty"`
+	// The channels to post matching messages to.
+	Channels []string `json:"channels"`
+	// Continue evaluating rules after this rule matches. By default the first matching rule wins.
+	Continue bool `json:"continue,omitempty"`
+	re       *regexp.Regexp
+}
+
+// Route describes the channels that a message is posted to.
+type Route struct {
+	// The channels to post the message to, in the order they were matched, without duplicates.
+	Channels []string
+	// The name of the rule which matched each channel, or ROUTE_DEFAULT, keyed by channel.
+	Rules map[string]string
+}
+
+// NewRouteRules returns a new `RouteRules` instance decoded from the JSON document in 'r'.
+func NewRouteRules(ctx context.Context, r io.Reader) (*RouteRules, error) {
+
+	var rules *RouteRules
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(&rules)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode rules, %w", err)
+	}
+
+	if rules == nil {
+		return nil, fmt.Errorf("Missing rules")
+	}
+
+	err = rules.compile()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// compile validates each rule in 'rr' and compiles its pattern.
+func (rr *RouteRules) compile() error {
+
+	if len(rr.Rules) == 0 && len(rr.Default) == 0 {
+		return fmt.Errorf("Rules do not define any rules or default channels")
+	}
+
+	for _, c := range rr.Default {
+
+		if !isValidRouteChannel(c) {
+			return fmt.Errorf("Invalid default channel '%s'", c)
+		}
+	}
+
+	for idx, rule := range rr.Rules {
+
+		if rule == nil {
+			return fmt.Errorf("Rule %d is empty", idx+1)
+		}
+
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("%d", idx+1)
+		}
+
+		if len(rule.Channels) == 0 {
+			return fmt.Errorf("Rule '%s' does not define any channels", rule.Name)
+		}
+
+		for _, c := range rule.Channels {
+
+			if !isValidRouteChannel(c) {
+				return fmt.Errorf("Invalid channel '%s' for rule '%s'", c, rule.Name)
+			}
+		}
+
+		if rule.Pattern != "" {
+
+			re, err := regexp.Compile(rule.Pattern)
+
+			if err != nil {
+				return fmt.Errorf("Invalid pattern for rule '%s', %w", rule.Name, err)
+			}
+
+			rule.re = re
+		}
+	}
+
+	return nil
+}
+
+// Route evaluates the rules in 'rr', in order, against 'msg' and returns the channels it should be posted to. If
+// no rules match the default channels are used. The returned `Route` has no channels if no rules match and
+// there are no default channels.
+func (rr *RouteRules) Route(msg *broadcaster.Message) *Route {
+
+	route := &Route{
+		Channels: make([]string, 0),
+		Rules:    make(map[string]string),
+	}
+
+	add := func(channels []string, rule string) {
+
+		for _, c := range channels {
+
+			c = strings.TrimPrefix(c, "#")
+
+			if _, exists := route.Rules[c]; exists {
+				continue
+			}
+
+			route.Channels = append(route.Channels, c)
+			route.Rules[c] = rule
+		}
+	}
+
+	for _, rule := range rr.Rules {
+
+		if !rule.matches(msg) {
+			continue
+		}
+
+		add(rule.Channels, rule.Name)
+
+		if !rule.Continue {
+			break
+		}
+	}
+
+	if len(route.Channels) == 0 {
+		add(rr.Default, ROUTE_DEFAULT)
+	}
+
+	return route
+}
+
+// isValidRouteChannel reports whether 'channel', with an optional leading "#", is a valid Slack channel ID or name.
+// Channel names are also used as outbox subdirectories so this ensures they can not contain path separators.
+func isValidRouteChannel(channel string) bool {
+	channel = strings.TrimPrefix(channel, "#")
+	return re_channel_id.MatchString(channel) || re_channel_name.MatchString(channel)
+}
+
+// matches reports whether 'msg' matches all of the conditions defined by 'rule'.
+func (rule *RouteRule) matches(msg *broadcaster.Message) bool {
+
+	if rule.re != nil && !rule.re.MatchString(msg.Title) && !rule.re.MatchString(msg.Body) {
+		return false
+	}
+
+	if rule.Prefix != "" {
+
+		text := msg.Title
+
+		if text == "" {
+			text = msg.Body
+		}
+
+		if !strings.HasPrefix(text, rule.Prefix) {
+			return false
+		}
+	}
+
+	if len(rule.Severity) > 0 {
+
+		severity := messageSeverity(msg)
+
+		if severity == "" {
+			return false
+		}
+
+		found := false
+
+		for _, s := range rule.Severity {
+
+			if strings.EqualFold(s, severity) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// messageSeverity returns the severity tag, like "ERROR", at the start of the title or body of 'msg' or an
+// empty string if there isn't one.
+func messageSeverity(msg *broadcaster.Message) string {
+
+	for _, text := range []string{msg.Title, msg.Body} {
+
+		m := re_severity.FindStringSubmatch(text)
+
+		if m != nil {
+			return m[1]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,400 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-uid"
+	gc "gocloud.dev/runtimevar"
+	"io"
+	"log"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrRouteBroadcasterClosed is returned when a message is broadcast by a `RouteBroadcaster` after it has been closed.
+var ErrRouteBroadcasterClosed = errors.New("Route broadcaster is closed")
+
+func init() {
+	ctx := context.Background()
+	broadcaster.RegisterBroadcaster(ctx, "slack-route", NewRouteBroadcaster)
+}
+
+// RouteUID implements the `uid.UID` interface for a message posted to one or more channels by a `RouteBroadcaster`.
+type RouteUID struct {
+	uid.UID
+	results []*DestinationResult
+}
+
+// Results returns the outcome for each channel the message was routed to, in the order they were matched.
+func (u *RouteUID) Results() []*DestinationResult {
+	return u.results
+}
+
+// Value returns the list of `DestinationResult` instances for 'u'.
+func (u *RouteUID) Value() any {
+	return u.results
+}
+
+// String returns 'u' in the form of "{CHANNEL}={UID}" for each channel, separated by spaces. Failed channels
+// are rendered as "{CHANNEL}=error".
+func (u *RouteUID) String() string {
+	return resultsString(u.results)
+}
+
+// RouteBroadcaster implements the `broadcaster.Broadcaster` interface by posting each message to the channels chosen
+// by evaluating a set of `RouteRules` against it. Rules are read from a runtimevar URI and reloaded whenever its
+// value changes.
+type RouteBroadcaster struct {
+	broadcaster.Broadcaster
+	options         *Options
+	rules           *RouteRules
+	rules_mu        *sync.RWMutex
+	variable        *gc.Variable
+	broadcasters    map[string]broadcaster.Broadcaster
+	broadcasters_mu *sync.Mutex
+	closed          bool
+	logger          *log.Logger
+	cancel          context.CancelFunc
+	done            chan bool
+	once            sync.Once
+}
+
+// NewRouteBroadcaster returns a new `RouteBroadcaster` configured by 'uri' which is expected to take the form of:
+//
+//	slack-route://?rules={RUNTIMEVAR_URI}&credentials={RUNTIMEVAR_URI}&{PARAMETERS}
+//
+// Where ?rules= is a valid `gocloud.dev/runtimevar` URI which dereferences to a JSON-encoded `RouteRules` document
+// and {PARAMETERS} are any of the parameters supported by `NewSlackBroadcaster`, which are applied to every channel.
+// Callers should invoke the `Close` method to stop watching for changes to the rules.
+func NewRouteBroadcaster(ctx context.Context, uri string) (broadcaster.Broadcaster, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	rules_uri := q.Get("rules")
+
+	if rules_uri == "" {
+		return nil, fmt.Errorf("Missing ?rules= parameter")
+	}
+
+	q.Del("rules")
+
+	// The channel is assigned for each route
+
+	opts, err := NewOptionsFromURI(ctx, fmt.Sprintf("slack://?%s", q.Encode()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRouteBroadcasterWithOptions(ctx, rules_uri, opts)
+}
+
+// NewRouteBroadcasterWithOptions returns a new `RouteBroadcaster` which posts messages using 'opts', with the
+// channel assigned by the rules read from 'rules_uri'. If 'opts' defines an outbox each channel uses its own
+// subdirectory of it.
+func NewRouteBroadcasterWithOptions(ctx context.Context, rules_uri string, opts *Options) (*RouteBroadcaster, error) {
+
+	if opts == nil {
+		return nil, fmt.Errorf("Missing options")
+	}
+
+	v, err := openRulesVariable(ctx, rules_uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open rules, %w", err)
+	}
+
+	rules, err := readRules(ctx, v)
+
+	if err != nil {
+		v.Close()
+		return nil, err
+	}
+
+	o := *opts
+
+	logger := o.Logger
+
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	// The watcher outlives the context used to create the broadcaster so it gets its own
+
+	watch_ctx, cancel := context.WithCancel(context.Background())
+
+	br := &RouteBroadcaster{
+		options:         &o,
+		rules:           rules,
+		rules_mu:        new(sync.RWMutex),
+		variable:        v,
+		broadcasters:    make(map[string]broadcaster.Broadcaster),
+		broadcasters_mu: new(sync.Mutex),
+		logger:          logger,
+		cancel:          cancel,
+		done:            make(chan bool),
+	}
+
+	go br.watch(watch_ctx)
+
+	return br, nil
+}
+
+// LoadRouteRules returns the `RouteRules` read from 'uri', a valid `gocloud.dev/runtimevar` URI, in the same way
+// that `NewRouteBroadcaster` reads its ?rules= parameter. The rules are read once and not watched for changes.
+func LoadRouteRules(ctx context.Context, uri string) (*RouteRules, error) {
+
+	v, err := openRulesVariable(ctx, uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open rules, %w", err)
+	}
+
+	defer v.Close()
+
+	return readRules(ctx, v)
+}
+
+// readRules returns the `RouteRules` for the initial value of 'v'. The first call to Watch returns as soon as
+// the initial value has been read, or has failed to be read, rather than waiting for a valid value.
+func readRules(ctx context.Context, v *gc.Variable) (*RouteRules, error) {
+
+	snapshot, err := v.Watch(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read rules, %w", err)
+	}
+
+	return NewRouteRules(ctx, strings.NewReader(snapshot.Value.(string)))
+}
+
+// openRulesVariable opens the `gocloud.dev/runtimevar` variable for 'uri', decoding its value as a string.
+func openRulesVariable(ctx context.Context, uri string) (*gc.Variable, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+
+	if q.Get("decoder") == "" {
+		q.Set("decoder", "string")
+		u.RawQuery = q.Encode()
+	}
+
+	return gc.OpenVariable(ctx, u.String())
+}
+
+// BroadcastMessage posts 'msg' to each of the channels chosen by the current rules, concurrently, and returns a
+// `RouteUID` with the outcome for each channel. If any channel fails a non-nil `RouteUID` is still returned, along
+// with an error which wraps each failure.
+func (br *RouteBroadcaster) BroadcastMessage(ctx context.Context, msg *broadcaster.Message) (uid.UID, error) {
+
+	route := br.Rules().Route(msg)
+
+	if len(route.Channels) == 0 {
+		return nil, fmt.Errorf("Message does not match any rules and there are no default channels")
+	}
+
+	destinations := make([]*namedBroadcaster, len(route.Channels))
+
+	for idx, channel := range route.Channels {
+
+		b, err := br.channelBroadcaster(ctx, channel)
+
+		if err != nil {
+			return nil, err
+		}
+
+		destinations[idx] = &namedBroadcaster{
+			name:        channel,
+			broadcaster: b,
+		}
+	}
+
+	results, err := broadcastAll(ctx, destinations, msg)
+
+	u := &RouteUID{
+		results: results,
+	}
+
+	return u, err
+}
+
+// Rules returns the current rules used by 'br'.
+func (br *RouteBroadcaster) Rules() *RouteRules {
+
+	br.rules_mu.RLock()
+	defer br.rules_mu.RUnlock()
+
+	return br.rules
+}
+
+// BroadcastSlackMessage posts 'msg', and its Slack-specific properties, to each of the channels chosen by the current
+// rules in the same way as the `BroadcastMessage` method.
+func (br *RouteBroadcaster) BroadcastSlackMessage(ctx context.Context, msg *Message) (uid.UID, error) {
+	return broadcastSlackMessage(ctx, br, msg)
+}
+
+// SetLogger assigns 'logger' to 'br' and the broadcasters for each channel it has posted to. The logger is guarded
+// by 'broadcasters_mu' since it is also read by the goroutine watching for changes to the rules.
+func (br *RouteBroadcaster) SetLogger(ctx context.Context, logger *log.Logger) error {
+
+	br.broadcasters_mu.Lock()
+	defer br.broadcasters_mu.Unlock()
+
+	br.logger = logger
+	br.options.Logger = logger
+
+	for channel, b := range br.broadcasters {
+
+		err := b.SetLogger(ctx, logger)
+
+		if err != nil {
+			return fmt.Errorf("Failed to set logger for %s, %w", channel, err)
+		}
+	}
+
+	return nil
+}
+
+// Close stops watching for changes to the rules and closes the broadcasters for each channel which need to be
+// closed, for example those with an outbox or a digest interval.
+func (br *RouteBroadcaster) Close() error {
+
+	errs := make([]error, 0)
+
+	br.once.Do(func() {
+
+		br.cancel()
+		<-br.done
+
+		err := br.variable.Close()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to close rules, %w", err))
+		}
+
+		br.broadcasters_mu.Lock()
+		defer br.broadcasters_mu.Unlock()
+
+		// Ensure that no more broadcasters, whose outbox or digest goroutines would never be stopped, are created
+
+		br.closed = true
+
+		for channel, b := range br.broadcasters {
+
+			cl, ok := b.(io.Closer)
+
+			if !ok {
+				continue
+			}
+
+			err := cl.Close()
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("Failed to close broadcaster for %s, %w", channel, err))
+			}
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// channelBroadcaster returns the broadcaster used to post messages to 'channel', creating it if necessary.
+func (br *RouteBroadcaster) channelBroadcaster(ctx context.Context, channel string) (broadcaster.Broadcaster, error) {
+
+	br.broadcasters_mu.Lock()
+	defer br.broadcasters_mu.Unlock()
+
+	if br.closed {
+		return nil, ErrRouteBroadcasterClosed
+	}
+
+	b, ok := br.broadcasters[channel]
+
+	if ok {
+		return b, nil
+	}
+
+	o := *br.options
+	o.Channel = channel
+	o.Logger = br.logger
+
+	// The channel is used as a path below so check it here rather than relying on the rules having been validated
+
+	if !isValidRouteChannel(channel) {
+		return nil, fmt.Errorf("Invalid channel '%s'", channel)
+	}
+
+	// Outbox items do not record their channel so each channel needs its own outbox
+
+	if o.Outbox != "" {
+		o.Outbox = filepath.Join(o.Outbox, channel)
+	}
+
+	b, err := NewSlackBroadcasterWithOptions(ctx, &o)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create broadcaster for %s, %w", channel, err)
+	}
+
+	br.broadcasters[channel] = b
+	return b, nil
+}
+
+// watch replaces the rules used by 'br' each time the value of the rules variable changes until 'ctx' is cancelled.
+// Invalid rules are logged and the previous rules are kept.
+func (br *RouteBroadcaster) watch(ctx context.Context) {
+
+	defer close(br.done)
+
+	for {
+
+		snapshot, err := br.variable.Watch(ctx)
+
+		if ctx.Err() != nil || errors.Is(err, gc.ErrClosed) {
+			return
+		}
+
+		if err != nil {
+			br.logf("Failed to read routing rules, keeping previous rules, %v\n", err)
+			continue
+		}
+
+		rules, err := NewRouteRules(ctx, strings.NewReader(snapshot.Value.(string)))
+
+		if err != nil {
+			br.logf("Failed to load routing rules, keeping previous rules, %v\n", err)
+			continue
+		}
+
+		br.rules_mu.Lock()
+		br.rules = rules
+		br.rules_mu.Unlock()
+
+		br.logf("Reloaded routing rules\n")
+	}
+}
+
+// logf writes a message to the logger for 'br', which may be replaced by `SetLogger` at any time.
+func (br *RouteBroadcaster) logf(format string, args ...any) {
+
+	br.broadcasters_mu.Lock()
+	logger := br.logger
+	br.broadcasters_mu.Unlock()
+
+	logger.Printf(format, args...)
+}
@@ -0,0 +1,138 @@
+package slack
+
+import (
+	"context"
+	"github.com/aaronland/go-broadcaster"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRouteRulesRoute(t *testing.T) {
+
+	ctx := context.Background()
+
+	doc := `{
+  "rules": [
+    { "name": "errors", "severity": [ "error", "critical" ], "channels": [ "alerts", "#oncall" ] },
+    { "name": "deploys", "prefix": "Deploy", "channels": [ "deploys" ], "continue": true },
+    { "name": "db", "pattern": "(?i)database", "channels": [ "dba", "deploys" ] },
+    { "severity": [ "warning" ], "pattern": "disk", "channels": [ "C0123456789" ] }
+  ],
+  "default": [ "general" ]
+}`
+
+	rules, err := NewRouteRules(ctx, strings.NewReader(doc))
+
+	if err != nil {
+		t.Fatalf("Failed to load rules, %v", err)
+	}
+
+	tests := map[string]struct {
+		msg      *broadcaster.Message
+		channels []string
+		rules    map[string]string
+	}{
+		"severity in title": {
+			msg:      &broadcaster.Message{Title: "[ERROR] Disk full"},
+			channels: []string{"alerts", "oncall"},
+			rules:    map[string]string{"alerts": "errors", "oncall": "errors"},
+		},
+		"severity in body": {
+			msg:      &broadcaster.Message{Body: " [critical] Database down"},
+			channels: []string{"alerts", "oncall"},
+			rules:    map[string]string{"alerts": "errors", "oncall": "errors"},
+		},
+		"continue": {
+			msg:      &broadcaster.Message{Title: "Deploy finished", Body: "Database migrated"},
+			channels: []string{"deploys", "dba"},
+			rules:    map[string]string{"deploys": "deploys", "dba": "db"},
+		},
+		"continue without further matches": {
+			msg:      &broadcaster.Message{Title: "Deploy finished"},
+			channels: []string{"deploys"},
+			rules:    map[string]string{"deploys": "deploys"},
+		},
+		"prefix uses title": {
+			msg:      &broadcaster.Message{Title: "Build finished", Body: "Deploy next"},
+			channels: []string{"general"},
+			rules:    map[string]string{"general": ROUTE_DEFAULT},
+		},
+		"prefix uses body without title": {
+			msg:      &broadcaster.Message{Body: "Deploy started"},
+			channels: []string{"deploys"},
+			rules:    map[string]string{"deploys": "deploys"},
+		},
+		"all conditions": {
+			msg:      &broadcaster.Message{Title: "[WARNING] disk at 90%"},
+			channels: []string{"C0123456789"},
+			rules:    map[string]string{"C0123456789": "4"},
+		},
+		"some conditions": {
+			msg:      &broadcaster.Message{Title: "[WARNING] memory at 90%"},
+			channels: []string{"general"},
+			rules:    map[string]string{"general": ROUTE_DEFAULT},
+		},
+		"default": {
+			msg:      &broadcaster.Message{Body: "Hello world"},
+			channels: []string{"general"},
+			rules:    map[string]string{"general": ROUTE_DEFAULT},
+		},
+	}
+
+	for name, tc := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			route := rules.Route(tc.msg)
+
+			if !reflect.DeepEqual(route.Channels, tc.channels) {
+				t.Fatalf("Expected channels %v but got %v", tc.channels, route.Channels)
+			}
+
+			if !reflect.DeepEqual(route.Rules, tc.rules) {
+				t.Fatalf("Expected rules %v but got %v", tc.rules, route.Rules)
+			}
+		})
+	}
+}
+
+func TestRouteRulesNoDefault(t *testing.T) {
+
+	rules, err := NewRouteRules(context.Background(), strings.NewReader(`{"rules":[{"prefix":"Deploy","channels":["deploys"]}]}`))
+
+	if err != nil {
+		t.Fatalf("Failed to load rules, %v", err)
+	}
+
+	route := rules.Route(&broadcaster.Message{Body: "Hello world"})
+
+	if len(route.Channels) != 0 {
+		t.Fatalf("Expected no channels but got %v", route.Channels)
+	}
+}
+
+func TestNewRouteRulesInvalid(t *testing.T) {
+
+	tests := map[string]string{
+		"empty":             `{}`,
+		"unknown field":     `{"default":["general"],"channel":"general"}`,
+		"no channels":       `{"rules":[{"name":"errors"}]}`,
+		"invalid pattern":   `{"rules":[{"pattern":"(","channels":["general"]}]}`,
+		"path in channel":   `{"rules":[{"channels":["../../etc"]}]}`,
+		"path in default":   `{"default":["general/../../etc"]}`,
+		"uppercase channel": `{"default":["General"]}`,
+	}
+
+	for name, doc := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			_, err := NewRouteRules(context.Background(), strings.NewReader(doc))
+
+			if err == nil {
+				t.Fatalf("Expected rules to be invalid")
+			}
+		})
+	}
+}
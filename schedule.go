@@ -0,0 +1,203 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-uid"
+	"github.com/tidwall/gjson"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The prefix for the string value of a `ScheduledMessageUID`.
+const scheduled_uid_prefix string = "scheduled:"
+
+// ScheduledMessageUID implements the `uid.UID` interface for a message scheduled for delivery to a Slack channel.
+type ScheduledMessageUID struct {
+	uid.UID
+	channel string
+	id      string
+	post_at time.Time
+}
+
+// NewScheduledMessageUID returns a new `ScheduledMessageUID` for the message with scheduled message ID 'id' which
+// will be posted to 'channel' at 'post_at'.
+func NewScheduledMessageUID(ctx context.Context, channel string, id string, post_at time.Time) (uid.UID, error) {
+
+	if id == "" {
+		return nil, fmt.Errorf("Missing scheduled message ID")
+	}
+
+	u := &ScheduledMessageUID{
+		channel: channel,
+		id:      id,
+		post_at: post_at,
+	}
+
+	return u, nil
+}
+
+// Channel returns the ID of the channel the message will be posted to.
+func (u *ScheduledMessageUID) Channel() string {
+	return u.channel
+}
+
+// ID returns the Slack scheduled message ID.
+func (u *ScheduledMessageUID) ID() string {
+	return u.id
+}
+
+// PostAt returns the time the message will be posted.
+func (u *ScheduledMessageUID) PostAt() time.Time {
+	return u.post_at
+}
+
+// Value returns the string representation of 'u'.
+func (u *ScheduledMessageUID) Value() any {
+	return u.String()
+}
+
+// String returns 'u' in the form of "scheduled:{CHANNEL_ID}/{SCHEDULED_MESSAGE_ID}". The prefix distinguishes
+// scheduled message IDs from the timestamps of posted messages.
+func (u *ScheduledMessageUID) String() string {
+	return fmt.Sprintf("%s%s/%s", scheduled_uid_prefix, u.channel, u.id)
+}
+
+// ScheduledMessage is a message which has been scheduled for delivery but not yet posted.
+type ScheduledMessage struct {
+	// The Slack scheduled message ID.
+	ID string `json:"id"`
+	// The ID of the channel the message will be posted to.
+	Channel string `json:"channel"`
+	// The time the message will be posted.
+	PostAt time.Time `json:"post_at"`
+	// The time the message was scheduled.
+	Created time.Time `json:"created"`
+	// The text of the message.
+	Text string `json:"text"`
+}
+
+// ListScheduled returns the list of messages scheduled for delivery to the channel associated with 'br'
+// using the chat.scheduledMessages.list API method.
+func (br *SlackBroadcaster) ListScheduled(ctx context.Context) ([]*ScheduledMessage, error) {
+
+	channel, err := br.channelID(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	args := url.Values{}
+	args.Set("channel", channel)
+
+	messages := make([]*ScheduledMessage, 0)
+
+	err = paginate(ctx, br.api, "chat.scheduledMessages.list", args, func(body []byte) {
+
+		for _, m := range gjson.GetBytes(body, "scheduled_messages").Array() {
+
+			msg := &ScheduledMessage{
+				ID:      m.Get("id").String(),
+				Channel: m.Get("channel_id").String(),
+				PostAt:  time.Unix(m.Get("post_at").Int(), 0),
+				Created: time.Unix(m.Get("date_created").Int(), 0),
+				Text:    m.Get("text").String(),
+			}
+
+			messages = append(messages, msg)
+		}
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list scheduled messages, %w", err)
+	}
+
+	return messages, nil
+}
+
+// CancelScheduled cancels the scheduled message 'id' using the chat.deleteScheduledMessage API method. 'id' may
+// be either a Slack scheduled message ID or the string value of a `ScheduledMessageUID`.
+func (br *SlackBroadcaster) CancelScheduled(ctx context.Context, id string) error {
+
+	channel := ""
+
+	id = strings.TrimPrefix(id, scheduled_uid_prefix)
+
+	if strings.Contains(id, "/") {
+		parts := strings.SplitN(id, "/", 2)
+		channel = parts[0]
+		id = parts[1]
+	}
+
+	if channel == "" {
+
+		c, err := br.channelID(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		channel = c
+	}
+
+	args := url.Values{}
+	args.Set("channel", channel)
+	args.Set("scheduled_message_id", id)
+
+	_, err := br.api(ctx, "chat.deleteScheduledMessage", args)
+
+	if err != nil {
+		return fmt.Errorf("Failed to cancel scheduled message %s, %w", id, err)
+	}
+
+	return nil
+}
+
+// schedulePayloads updates 'payloads' to be delivered at 'post_at' using the chat.scheduleMessage API method.
+func schedulePayloads(payloads []*payload, post_at time.Time) error {
+
+	if !post_at.After(time.Now()) {
+		return fmt.Errorf("Scheduled time %s is in the past", post_at.Format(time.RFC3339))
+	}
+
+	for _, p := range payloads {
+
+		if p.file != nil {
+			return fmt.Errorf("Scheduled messages can not include files or images")
+		}
+
+		p.method = "chat.scheduleMessage"
+		p.args.Set("post_at", strconv.FormatInt(post_at.Unix(), 10))
+
+		// Scheduled messages do not have a timestamp until they are posted so there is no thread to reply to
+		p.thread = false
+	}
+
+	return nil
+}
+
+// parsePostAt parses 'str' as either an RFC3339 timestamp, a Unix timestamp or a duration relative to 'now'.
+func parsePostAt(str string, now time.Time) (time.Time, error) {
+
+	t, err := time.Parse(time.RFC3339, str)
+
+	if err == nil {
+		return t, nil
+	}
+
+	ts, err := strconv.ParseInt(str, 10, 64)
+
+	if err == nil {
+		return time.Unix(ts, 0), nil
+	}
+
+	d, err := time.ParseDuration(str)
+
+	if err == nil {
+		return now.Add(d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid time '%s', expected an RFC3339 string, a Unix timestamp or a duration", str)
+}
@@ -8,21 +8,26 @@ import (
 	"github.com/aaronland/go-broadcaster"
 	"github.com/aaronland/go-image-encode"
 	"github.com/aaronland/go-uid"
-	"github.com/sfomuseum/runtimevar"
-	"github.com/tidwall/gjson"
 	"github.com/whosonfirst/go-ioutil"
+	"go.opencensus.io/trace"
 	"image"
 	"io"
 	"log"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
-const SLACK_API_UPLOAD string = "https://slack.com/api/files.upload"
-const SLACK_API_CHAT string = "https://slack.com/api/chat.postMessage"
+const SLACK_API_UPLOAD string = SLACK_API_ENDPOINT + "files.upload"
+const SLACK_API_CHAT string = SLACK_API_ENDPOINT + "chat.postMessage"
+
+// Matches Slack channel, private group and direct message IDs. Channel names are always lower case.
+var re_channel_id = regexp.MustCompile(`^[CGD][A-Z0-9]{6,}$`)
 
 func init() {
 	ctx := context.Background()
@@ -35,34 +40,168 @@ type SlackBroadcaster struct {
 	channel     string
 	token       string
 	encoder     encode.Encoder
-	logger      *log.Logger
+	mentions    *MentionPolicy
+	blocks      bool
+	resolver    *mentionResolver
+	// resolve_mentions signals that @handles and #channel names in message text should be resolved
+	resolve_mentions bool
+	unresolved       string
+	max_length       int
+	overflow         string
+	snippet_lines    int
+	snippet_type     string
+	// post_at is the (unparsed) time, or delay, at which messages should be scheduled for delivery
+	post_at string
+	// ephemeral_user is the ID of the user that messages should be posted as ephemeral messages for
+	ephemeral_user string
+	identity       *Identity
+	post_options   *PostOptions
+	// pin signals that messages should be pinned to the channel after they are posted
+	pin bool
+	// idempotency is the key store used to record messages which have been broadcast, if duplicates should be suppressed
+	idempotency        KeyStore
+	idempotency_window time.Duration
+	// rate_limiter is the (shared) rate limiter that API requests wait on, if not nil
+	rate_limiter *RateLimiter
+	// max_retries is the number of times a request which is rate limited by Slack is retried
+	max_retries int
+	// logger and slogger, the structured logger used to log API calls, are guarded by logger_mu since they may
+	// be replaced by SetLogger while a message is being broadcast
+	logger         *log.Logger
+	logger_mu      *sync.Mutex
+	slogger        *slog.Logger
+	custom_slogger bool
+	debug          bool
+	// dry_run signals that API requests which post messages should be written to dry_run_writer (or the logger) instead of being sent
+	dry_run        bool
+	dry_run_writer io.Writer
+	// team is the ID of the workspace that API requests target, for organization-wide tokens
+	team string
 }
 
+// NewSlackBroadcaster returns a new broadcaster for posting messages to Slack configured by 'uri' which is expected
+// to take the form of:
+//
+//	slack://{CHANNEL}?credentials={RUNTIMEVAR_URI}&{PARAMETERS}
+//
+// See `NewOptionsFromURI` for details.
 func NewSlackBroadcaster(ctx context.Context, uri string) (broadcaster.Broadcaster, error) {
 
-	u, err := url.Parse(uri)
+	opts, err := NewOptionsFromURI(ctx, uri)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+		return nil, err
 	}
 
-	channel := u.Host
+	return NewSlackBroadcasterWithOptions(ctx, opts)
+}
+
+// NewSlackBroadcasterWithOptions returns a new broadcaster for posting messages to Slack configured by 'opts'. The
+// returned broadcaster is a `SlackBroadcaster` instance unless 'opts' defines an outbox or a digest interval in which
+// case it is wrapped by an `OutboxBroadcaster` or `DigestBroadcaster` instance (or both).
+func NewSlackBroadcasterWithOptions(ctx context.Context, opts *Options) (broadcaster.Broadcaster, error) {
 
-	q := u.Query()
+	if opts == nil {
+		return nil, fmt.Errorf("Missing options")
+	}
 
-	creds_uri := q.Get("credentials")
+	// Work with a copy so that assigning defaults does not modify the caller's options
 
-	if creds_uri == "" {
-		return nil, fmt.Errorf("Missing ?credentials= parameter")
+	o := *opts
+
+	if o.Token == "" {
+		return nil, fmt.Errorf("Invalid options, Missing token")
 	}
 
-	rt_ctx, rt_cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer rt_cancel()
+	err := o.validate()
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid options, %w", err)
+	}
 
-	token, err := runtimevar.StringVar(rt_ctx, creds_uri)
+	br, err := newSlackBroadcaster(ctx, &o)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to derive URI from credentials, %w", err)
+		return nil, err
+	}
+
+	// Dry runs never call the Slack API so the token's scopes are not checked and mentions are not resolved
+
+	if o.DryRun {
+
+		if o.ResolveMentions {
+			br.logf("Mentions are not resolved in dry-run mode\n")
+		}
+
+		if o.Outbox != "" {
+			br.logf("Messages are not written to the outbox in dry-run mode\n")
+		}
+
+	} else if !o.Identity.IsZero() {
+		br.checkIdentityScope(ctx)
+	}
+
+	var b broadcaster.Broadcaster = br
+
+	// Rendered messages are never written to the outbox, where they might later be delivered by a broadcaster which is not in dry-run mode
+
+	if o.Outbox != "" && !o.DryRun {
+
+		outbox, err := NewOutbox(ctx, o.Outbox)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create outbox, %w", err)
+		}
+
+		ob, err := NewOutboxBroadcaster(ctx, b, outbox, o.OutboxOptions)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ob.SetLogger(ctx, o.Logger)
+		b = ob
+	}
+
+	// Digests wrap the outbox, if present, so that a digest which can not be posted is retried as a whole
+
+	if o.Digest > 0 {
+
+		db, err := NewDigestBroadcaster(ctx, b, o.Digest, o.DigestMax)
+
+		if err != nil {
+			return nil, err
+		}
+
+		db.close_target = true
+		db.SetLogger(ctx, o.Logger)
+		b = db
+	}
+
+	return b, nil
+}
+
+// newSlackBroadcaster returns a new `SlackBroadcaster` instance configured by 'o', which is expected to have been
+// validated, without making any API calls or wrapping it in any other broadcasters.
+func newSlackBroadcaster(ctx context.Context, o *Options) (*SlackBroadcaster, error) {
+
+	http_client := o.HTTPClient
+
+	if http_client == nil {
+
+		timeout := o.Timeout
+
+		if timeout == NO_TIMEOUT {
+			timeout = 0
+		}
+
+		cl, err := newHTTPClient(timeout, o.Proxy, o.CABundle)
+
+		if err != nil {
+			return nil, err
+		}
+
+		http_client = cl
 	}
 
 	enc, err := encode.NewEncoder(ctx, "png://")
@@ -71,106 +210,386 @@ func NewSlackBroadcaster(ctx context.Context, uri string) (broadcaster.Broadcast
 		return nil, fmt.Errorf("Failed to create image encoder, %w", err)
 	}
 
-	http_client := &http.Client{}
-	logger := log.Default()
+	snippet_lines := o.SnippetLines
+
+	if snippet_lines == NO_SNIPPET_LINES {
+		snippet_lines = 0
+	}
 
 	br := &SlackBroadcaster{
-		http_client: http_client,
-		channel:     channel,
-		token:       token,
-		encoder:     enc,
-		logger:      logger,
+		http_client:        http_client,
+		channel:            o.Channel,
+		token:              o.Token,
+		encoder:            enc,
+		logger:             o.Logger,
+		logger_mu:          new(sync.Mutex),
+		mentions:           o.AllowMentions,
+		blocks:             o.Blocks,
+		resolve_mentions:   o.ResolveMentions && !o.DryRun,
+		unresolved:         o.UnresolvedMentions,
+		max_length:         o.MaxLength,
+		overflow:           o.Overflow,
+		snippet_lines:      snippet_lines,
+		snippet_type:       o.SnippetType,
+		post_at:            o.PostAt,
+		ephemeral_user:     o.EphemeralUser,
+		identity:           o.Identity,
+		post_options:       o.PostOptions,
+		pin:                o.Pin,
+		idempotency:        o.IdempotencyStore,
+		idempotency_window: o.IdempotencyWindow,
+		rate_limiter:       o.RateLimiter,
+		max_retries:        o.MaxRetries,
+		debug:              o.Debug,
+		dry_run:            o.DryRun,
+		dry_run_writer:     o.DryRunWriter,
+		team:               o.Team,
 	}
 
+	if o.StructuredLogger != nil {
+		br.slogger = o.StructuredLogger
+		br.custom_slogger = true
+	} else {
+		br.slogger = newStructuredLogger(o.Logger, o.Debug)
+	}
+
+	br.resolver = newMentionResolver(br.api, o.MentionCacheTTL)
+
 	return br, nil
 }
 
+// BroadcastMessage posts 'msg' to the broadcaster's channel. The work is traced as a span, which is a child of
+// any span in 'ctx', with a child span for each image encoded, each file uploaded and each API call.
 func (br *SlackBroadcaster) BroadcastMessage(ctx context.Context, msg *broadcaster.Message) (uid.UID, error) {
 
-	if len(msg.Images) > 0 {
-		return br.broadcastMessageWithImages(ctx, msg)
+	slack_msg := &Message{
+		Message: msg,
 	}
 
-	return br.broadcastMessage(ctx, msg)
+	return br.BroadcastSlackMessage(ctx, slack_msg)
 }
 
-func (br *SlackBroadcaster) broadcastMessage(ctx context.Context, msg *broadcaster.Message) (uid.UID, error) {
+// BroadcastSlackMessage posts 'msg', and any Slack-specific properties it defines, to the broadcaster's channel.
+// The work is traced in the same way as the `BroadcastMessage` method.
+func (br *SlackBroadcaster) BroadcastSlackMessage(ctx context.Context, msg *Message) (uid.UID, error) {
 
-	msg_text := fmt.Sprintf("%s %s", msg.Title, msg.Body)
-	msg_text = strings.TrimSpace(msg_text)
+	if msg == nil || msg.Message == nil {
+		return nil, fmt.Errorf("Missing message")
+	}
 
-	args := url.Values{}
-	args.Set("channel", br.channel)
-	args.Set("text", msg_text)
+	ctx, span := trace.StartSpan(ctx, "slack.BroadcastMessage")
 
-	args_enc := args.Encode()
-	args_r := strings.NewReader(args_enc)
+	span.AddAttributes(
+		trace.StringAttribute(ATTR_CHANNEL, br.channel),
+		trace.Int64Attribute(ATTR_IMAGES, int64(len(msg.Images))),
+	)
 
-	req, err := http.NewRequest("POST", SLACK_API_CHAT, args_r)
+	id, err := br.broadcastMessage(ctx, msg)
 
-	if err != nil {
-		return nil, fmt.Errorf("Failed to create new chat request, %w", err)
+	if err == nil {
+		span.AddAttributes(trace.StringAttribute(ATTR_MESSAGE_ID, id.String()))
 	}
 
-	req.Header.Set("Content-type", "application/x-www-form-urlencoded")
+	endSpan(span, err)
+	return id, err
+}
 
-	rsp, err := br.call(ctx, req)
+// broadcastMessage posts 'msg' to the broadcaster's channel.
+func (br *SlackBroadcaster) broadcastMessage(ctx context.Context, msg *Message) (uid.UID, error) {
+
+	idempotency_key := ""
+
+	// Rendered messages are neither checked against, nor recorded in, the key store
+
+	if br.idempotency != nil && !br.dry_run {
+
+		k, err := br.idempotencyKey(ctx, msg)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to derive idempotency key, %w", err)
+		}
+
+		dupe_id, reserved, err := br.reserve(ctx, k)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !reserved {
+			trace.FromContext(ctx).Annotate(nil, "Skipping duplicate message")
+			br.logf("Skipping duplicate message %s\n", dupe_id.String())
+			return dupe_id, nil
+		}
+
+		idempotency_key = k
+	}
+
+	id, ephemeral, scheduled, err := br.prepareAndSend(ctx, msg)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to call the Slack API, %w", err)
+
+		// Release the key so that the message can be retried
+
+		if idempotency_key != "" {
+
+			release_err := br.release(ctx, idempotency_key)
+
+			if release_err != nil {
+				br.logf("%v\n", release_err)
+			}
+		}
+
+		return nil, err
+	}
+
+	// Messages which were only rendered are neither pinned nor recorded as having been broadcast
+
+	if br.dry_run {
+		return id, nil
+	}
+
+	if br.pin && !ephemeral && !scheduled {
+
+		// The message has already been posted so failing to pin it is not treated as an error
+		// since that might cause the caller to post it again.
+
+		err := br.Pin(ctx, id)
+
+		if err != nil {
+			br.logf("Failed to pin message %s, %v\n", id.String(), err)
+		}
+	}
+
+	if idempotency_key != "" {
+
+		// As with pinning, the message has already been posted so failing to record it is only logged
+
+		err := br.recordBroadcast(ctx, idempotency_key, id)
+
+		if err != nil {
+			br.logf("Failed to record message %s, %v\n", id.String(), err)
+		}
 	}
 
-	body, err := io.ReadAll(rsp)
+	return id, nil
+}
+
+// prepareAndSend derives the payloads for 'msg' and sends them, returning the UID of the message and whether it is
+// ephemeral or scheduled.
+func (br *SlackBroadcaster) prepareAndSend(ctx context.Context, msg *Message) (uid.UID, bool, bool, error) {
+
+	payloads, ephemeral, scheduled, err := br.preparePayloads(ctx, msg)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to read API response, %w", err)
+		return nil, false, false, err
 	}
 
-	ok_rsp := gjson.GetBytes(body, "ok")
+	id, err := br.send(ctx, payloads)
 
-	if !ok_rsp.Bool() {
-		err_rsp := gjson.GetBytes(body, "error")
-		// something something something list errors
-		return nil, fmt.Errorf("API returned an error, %s", err_rsp.String())
+	if err != nil {
+		return nil, false, false, err
 	}
 
-	// there isn't really an ID property in these responses
-	return br.uid(ctx)
+	return id, ephemeral, scheduled, nil
 }
 
-func (br *SlackBroadcaster) broadcastMessageWithImages(ctx context.Context, msg *broadcaster.Message) (uid.UID, error) {
+// preparePayloads returns the list of Slack API requests to make in order to broadcast 'msg', with any scheduling,
+// ephemeral, post options and metadata settings from the broadcaster, 'msg' and 'ctx' applied, and whether the message
+// is ephemeral or scheduled.
+func (br *SlackBroadcaster) preparePayloads(ctx context.Context, msg *Message) ([]*payload, bool, bool, error) {
+
+	md, has_metadata := metadataFromContext(ctx)
+
+	if msg.Metadata != nil {
+		md = msg.Metadata
+		has_metadata = true
+	}
+
+	// Metadata can not be attached to a file upload so if there is metadata the text of a message with images
+	// is posted separately rather than as the comment for the first image
+
+	payloads, err := br.payloads(ctx, msg.Message, has_metadata)
+
+	if err != nil {
+		return nil, false, false, fmt.Errorf("Failed to derive payloads for message, %w", err)
+	}
+
+	post_at, scheduled := postAtFromContext(ctx)
+
+	if !scheduled && br.post_at != "" {
+
+		t, err := parsePostAt(br.post_at, time.Now())
+
+		if err != nil {
+			return nil, false, false, fmt.Errorf("Failed to derive scheduled time, %w", err)
+		}
+
+		post_at = t
+		scheduled = true
+	}
+
+	ephemeral_user, ephemeral := ephemeralUserFromContext(ctx)
+
+	if !ephemeral && br.ephemeral_user != "" {
+		ephemeral_user = br.ephemeral_user
+		ephemeral = true
+	}
+
+	if ephemeral && scheduled {
+		return nil, false, false, fmt.Errorf("Ephemeral messages can not be scheduled")
+	}
+
+	if ephemeral {
+
+		err := ephemeralPayloads(payloads, ephemeral_user)
+
+		if err != nil {
+			return nil, false, false, fmt.Errorf("Failed to derive ephemeral message, %w", err)
+		}
+	}
+
+	if scheduled {
+
+		err := schedulePayloads(payloads, post_at)
+
+		if err != nil {
+			return nil, false, false, fmt.Errorf("Failed to schedule message, %w", err)
+		}
+	}
+
+	post_options := br.post_options
 
-	for idx, im := range msg.Images {
+	ctx_options, ok := postOptionsFromContext(ctx)
 
-		args := &url.Values{}
-		args.Set("channels", br.channel)
+	if ok {
 
-		if idx == 0 {
-			msg_text := fmt.Sprintf("%s %s", msg.Title, msg.Body)
-			msg_text = strings.TrimSpace(msg_text)
-			args.Set("text", msg_text)
+		post_options = post_options.merge(ctx_options)
+
+		err := post_options.Validate()
+
+		if err != nil {
+			return nil, false, false, fmt.Errorf("Invalid post options, %w", err)
 		}
 
-		err := br.uploadImage(ctx, im, args)
+		err = post_options.checkMentions(br.mentions)
 
 		if err != nil {
-			return nil, fmt.Errorf("Failed to upload image, %w", err)
+			return nil, false, false, fmt.Errorf("Invalid post options, %w", err)
 		}
+	}
 
+	for _, p := range payloads {
+		post_options.apply(p.method, p.args)
+		p.args = br.withTeam(p.args)
 	}
 
-	// Unlike the chat API there are ID properties in file upload responses but
-	// ultimately they are not relevant for doing anything with the message that
-	// is posted to a channel
-	return br.uid(ctx)
+	if has_metadata {
+
+		err := md.Validate()
+
+		if err != nil {
+			return nil, false, false, fmt.Errorf("Invalid metadata, %w", err)
+		}
+
+		err = attachMetadata(payloads, md)
+
+		if err != nil {
+			return nil, false, false, fmt.Errorf("Failed to attach metadata, %w", err)
+		}
+	}
+
+	return payloads, ephemeral, scheduled, nil
 }
 
+// formatText escapes 'text' according to the broadcaster's mention policy and, if enabled, resolves
+// any @handles and #channel names it contains in to Slack mention tokens.
+func (br *SlackBroadcaster) formatText(ctx context.Context, text string) (string, error) {
+
+	text = br.mentions.Escape(text)
+
+	if !br.resolve_mentions {
+		return text, nil
+	}
+
+	text, unresolved, err := br.resolver.Resolve(ctx, text, br.mentions)
+
+	// Failing to retrieve a list of users, user groups or channels is handled the same way as a reference
+	// which can not be resolved, since the text returned by Resolve leaves those references as-is.
+
+	if err != nil {
+
+		switch br.unresolved {
+		case UNRESOLVED_ERROR:
+			return "", fmt.Errorf("Failed to resolve mentions, %w", err)
+		case UNRESOLVED_WARN:
+			br.logf("Failed to resolve mentions, %v\n", err)
+		}
+	}
+
+	if len(unresolved) > 0 {
+
+		switch br.unresolved {
+		case UNRESOLVED_ERROR:
+			return "", fmt.Errorf("Failed to resolve mentions: %s", strings.Join(unresolved, ", "))
+		case UNRESOLVED_WARN:
+			br.logf("Unable to resolve mentions: %s\n", strings.Join(unresolved, ", "))
+		}
+	}
+
+	return text, nil
+}
+
+// channelID returns the ID of the channel associated with 'br', looking it up by name if necessary.
+func (br *SlackBroadcaster) channelID(ctx context.Context) (string, error) {
+
+	if re_channel_id.MatchString(br.channel) {
+		return br.channel, nil
+	}
+
+	id, ok, err := br.resolver.ChannelID(ctx, br.channel)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to resolve channel ID for '%s', %w", br.channel, err)
+	}
+
+	if !ok {
+		return "", fmt.Errorf("Unable to find channel '%s'", br.channel)
+	}
+
+	return id, nil
+}
+
+// SetRateLimiter assigns 'l' as the rate limiter that API requests made by 'br' wait on. If 'l' is nil requests
+// are not rate limited.
+func (br *SlackBroadcaster) SetRateLimiter(l *RateLimiter) {
+	br.rate_limiter = l
+}
+
+// RateLimiter returns the rate limiter that API requests made by 'br' wait on, which may be nil.
+func (br *SlackBroadcaster) RateLimiter() *RateLimiter {
+	return br.rate_limiter
+}
+
+// SetLogger assigns 'logger' to 'br'. Unless a structured logger has been assigned using the `SetStructuredLogger`
+// method, API calls are also logged to the same destination as 'logger'. It is safe to call while messages are being broadcast.
 func (br *SlackBroadcaster) SetLogger(ctx context.Context, logger *log.Logger) error {
+
+	br.logger_mu.Lock()
+	defer br.logger_mu.Unlock()
+
 	br.logger = logger
+
+	if !br.custom_slogger {
+		br.slogger = newStructuredLogger(logger, br.debug)
+	}
+
 	return nil
 }
 
-func (br *SlackBroadcaster) uploadImage(ctx context.Context, im image.Image, args *url.Values) error {
+// encodeImage returns the encoded bytes for 'im'.
+func (br *SlackBroadcaster) encodeImage(ctx context.Context, im image.Image) ([]byte, error) {
+
+	ctx, span := trace.StartSpan(ctx, "slack.encodeImage")
 
 	var buf bytes.Buffer
 	wr := bufio.NewWriter(&buf)
@@ -178,16 +597,33 @@ func (br *SlackBroadcaster) uploadImage(ctx context.Context, im image.Image, arg
 	err := br.encoder.Encode(ctx, im, wr)
 
 	if err != nil {
-		return fmt.Errorf("Failed to encode image, %w", err)
+		err = fmt.Errorf("Failed to encode image, %w", err)
+		endSpan(span, err)
+		return nil, err
 	}
 
 	wr.Flush()
 
-	b_r := bytes.NewReader(buf.Bytes())
-	return br.uploadReader(ctx, b_r, args)
+	span.AddAttributes(trace.Int64Attribute(ATTR_BYTES, int64(buf.Len())))
+	endSpan(span, nil)
+
+	return buf.Bytes(), nil
 }
 
-func (br *SlackBroadcaster) uploadReader(ctx context.Context, r io.Reader, args *url.Values) error {
+// uploadReader uploads the contents of 'r' as 'filename' using the files.upload API method.
+func (br *SlackBroadcaster) uploadReader(ctx context.Context, r io.Reader, filename string, args *url.Values) ([]byte, error) {
+
+	ctx, span := trace.StartSpan(ctx, "slack.uploadFile")
+	span.AddAttributes(trace.StringAttribute(ATTR_FILENAME, filename))
+
+	body, err := br.upload(ctx, r, filename, args)
+
+	endSpan(span, err)
+	return body, err
+}
+
+// upload streams the contents of 'r' to the files.upload API method as a multipart form.
+func (br *SlackBroadcaster) upload(ctx context.Context, r io.Reader, filename string, args *url.Values) ([]byte, error) {
 
 	pipe_r, pipe_wr := io.Pipe()
 
@@ -199,7 +635,7 @@ func (br *SlackBroadcaster) uploadReader(ctx context.Context, r io.Reader, args
 
 		defer pipe_wr.Close()
 
-		ioWriter, err := wr.CreateFormFile("file", "upload.png")
+		ioWriter, err := wr.CreateFormFile("file", filename)
 
 		if err != nil {
 			err_ch <- fmt.Errorf("Failed to create form, %w", err)
@@ -228,7 +664,7 @@ func (br *SlackBroadcaster) uploadReader(ctx context.Context, r io.Reader, args
 	req, err := http.NewRequest("POST", SLACK_API_UPLOAD, pipe_r)
 
 	if err != nil {
-		return fmt.Errorf("Failed to create new request, %w", err)
+		return nil, fmt.Errorf("Failed to create new request, %w", err)
 	}
 
 	req.Header.Add("Content-Type", wr.FormDataContentType())
@@ -236,39 +672,55 @@ func (br *SlackBroadcaster) uploadReader(ctx context.Context, r io.Reader, args
 	rsp, err := br.call(ctx, req)
 
 	if err != nil {
-		return fmt.Errorf("Failed to call the Slack API, %w", err)
+		return nil, fmt.Errorf("Failed to call the Slack API, %w", err)
 	}
 
 	defer rsp.Close()
 
 	select {
 	case err := <-err_ch:
-		return fmt.Errorf("There was a problem upload your file, %w", err)
+		return nil, fmt.Errorf("There was a problem upload your file, %w", err)
 	default:
 		//
 	}
 
-	return nil
+	return readAPIResponse("files.upload", rsp)
 }
 
+// call sends 'req' and returns the body of the response if the request was successful.
 func (br *SlackBroadcaster) call(ctx context.Context, req *http.Request) (io.ReadSeekCloser, error) {
 
-	req = req.WithContext(ctx)
+	rsp, body, err := br.exchange(ctx, req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API call failed with status '%s'", rsp.Status)
+	}
+
+	return ioutil.NewReadSeekCloser(bytes.NewReader(body))
+}
 
-	bearer_token := fmt.Sprintf("Bearer %s", br.token)
+// do assigns the broadcaster's API token to 'req' and executes it.
+func (br *SlackBroadcaster) do(ctx context.Context, req *http.Request) (*http.Response, error) {
 
-	req.Header.Set("Authorization", bearer_token)
+	req = req.WithContext(ctx)
+
+	br.authorize(req)
 	rsp, err := br.http_client.Do(req)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to execute HTTP request, %w", err)
 	}
 
-	if rsp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API call failed with status '%s'", rsp.Status)
-	}
+	return rsp, nil
+}
 
-	return ioutil.NewReadSeekCloser(rsp.Body)
+// authorize assigns the broadcaster's API token to the Authorization header of 'req'.
+func (br *SlackBroadcaster) authorize(req *http.Request) {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", br.token))
 }
 
 func (br *SlackBroadcaster) uid(ctx context.Context) (uid.UID, error) {
@@ -0,0 +1,125 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// OVERFLOW_SNIPPET posts a summary of messages which are too long to send as a single Slack message
+// and attaches the full body as an uploaded text file.
+const OVERFLOW_SNIPPET string = "snippet"
+
+// Types of text snippets uploaded by the OVERFLOW_SNIPPET strategy.
+const (
+	// SNIPPET_AUTO signals that the type of a snippet should be derived from its contents.
+	SNIPPET_AUTO string = "auto"
+	// SNIPPET_TEXT is a plain text snippet.
+	SNIPPET_TEXT string = "text"
+	// SNIPPET_LOG is a log file snippet.
+	SNIPPET_LOG string = "log"
+	// SNIPPET_DIFF is a unified diff snippet.
+	SNIPPET_DIFF string = "diff"
+	// SNIPPET_JSON is a JSON snippet.
+	SNIPPET_JSON string = "json"
+)
+
+// The default number of lines of a message body to include in the summary posted with a snippet.
+const default_snippet_lines int = 10
+
+// NO_SNIPPET_LINES is the value of `Options.SnippetLines` which signals that the summary posted with a snippet
+// should not include any lines of the message body.
+const NO_SNIPPET_LINES int = -1
+
+var re_diff = regexp.MustCompile(`(?m)^(?:diff --git |--- \S|\+\+\+ \S|@@ -\d)`)
+
+// snippetType describes the file name, Slack file type and MIME type of a snippet.
+type snippetType struct {
+	filename     string
+	filetype     string
+	content_type string
+}
+
+var snippet_types = map[string]*snippetType{
+	SNIPPET_TEXT: {"message.txt", "text", "text/plain"},
+	SNIPPET_LOG:  {"message.log", "text", "text/plain"},
+	SNIPPET_DIFF: {"message.diff", "diff", "text/x-diff"},
+	SNIPPET_JSON: {"message.json", "json", "application/json"},
+}
+
+// isValidSnippetType reports whether 't' is a valid snippet type.
+func isValidSnippetType(t string) bool {
+
+	if t == SNIPPET_AUTO {
+		return true
+	}
+
+	_, ok := snippet_types[t]
+	return ok
+}
+
+// detectSnippetType returns the snippet type for 'body'.
+func detectSnippetType(body string) string {
+
+	trimmed := strings.TrimSpace(body)
+
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return SNIPPET_JSON
+	}
+
+	if len(re_diff.FindAllStringIndex(body, 3)) >= 2 {
+		return SNIPPET_DIFF
+	}
+
+	return SNIPPET_LOG
+}
+
+// snippetPayload returns a files.upload payload for 'body' (the unescaped message body) whose comment is 'summary'.
+func (br *SlackBroadcaster) snippetPayload(body string, summary string) (*payload, error) {
+
+	t := br.snippet_type
+
+	if t == SNIPPET_AUTO {
+		t = detectSnippetType(body)
+	}
+
+	st, ok := snippet_types[t]
+
+	if !ok {
+		return nil, fmt.Errorf("Invalid snippet type '%s'", t)
+	}
+
+	p := &payload{
+		method: "files.upload",
+		args:   url.Values{},
+		file: &payloadFile{
+			name:         st.filename,
+			content_type: st.content_type,
+			body:         []byte(body),
+		},
+	}
+
+	p.args.Set("channels", br.channel)
+	p.args.Set("filetype", st.filetype)
+
+	if summary != "" {
+		p.args.Set("initial_comment", summary)
+	}
+
+	return p, nil
+}
+
+// snippetSummary returns the first 'lines' lines of 'body', preceded by 'title' and truncated to 'max' characters.
+func snippetSummary(title string, body string, lines int, max int) string {
+
+	body_lines := strings.Split(body, "\n")
+
+	if len(body_lines) > lines {
+		body_lines = append(body_lines[:lines], "…")
+	}
+
+	summary := strings.TrimSpace(fmt.Sprintf("%s\n%s", title, strings.Join(body_lines, "\n")))
+	return splitText(summary, max)[0]
+}
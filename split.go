@@ -0,0 +1,299 @@
+package slack
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The maximum number of characters in a single message. Slack will accept up to 40,000 characters
+// but truncates, and recommends against, messages longer than 4,000 characters.
+const default_max_length int = 4000
+
+// The characters used to open and close a code fence.
+const code_fence string = "```"
+
+// splitText splits 'text' in to one or more chunks none of which are longer than 'limit' characters. Text is
+// split on paragraph boundaries where possible, then on line boundaries and then, as a last resort, on word
+// or character boundaries. Code fences are never broken: if a chunk ends inside a code fence the fence is
+// closed and then reopened at the start of the next chunk.
+func splitText(text string, limit int) []string {
+	return splitTextFirst(text, limit, limit)
+}
+
+// splitTextFirst splits 'text' in the same way as splitText except that the first chunk is no longer than 'first'
+// characters, leaving room for text (like a message title) which will be prepended to it.
+func splitTextFirst(text string, first int, limit int) []string {
+
+	if utf8.RuneCountInString(text) <= first {
+		return []string{text}
+	}
+
+	c := &chunker{
+		limit:  first,
+		next:   limit,
+		chunks: make([]string, 0),
+	}
+
+	for _, p := range splitParagraphs(text) {
+		c.addParagraph(p)
+	}
+
+	c.flush("")
+	return c.chunks
+}
+
+// splitParagraphs splits 'text' in to paragraphs separated by blank lines which are not inside a code fence.
+func splitParagraphs(text string) []string {
+
+	paragraphs := make([]string, 0)
+	current := make([]string, 0)
+
+	in_fence := false
+
+	for _, ln := range strings.Split(text, "\n") {
+
+		if isFenceLine(ln) {
+			in_fence = !in_fence
+		}
+
+		if !in_fence && strings.TrimSpace(ln) == "" {
+
+			if len(current) > 0 {
+				paragraphs = append(paragraphs, strings.Join(current, "\n"))
+				current = make([]string, 0)
+			}
+
+			continue
+		}
+
+		current = append(current, ln)
+	}
+
+	if len(current) > 0 {
+		paragraphs = append(paragraphs, strings.Join(current, "\n"))
+	}
+
+	return paragraphs
+}
+
+func isFenceLine(ln string) bool {
+	return strings.HasPrefix(strings.TrimSpace(ln), code_fence)
+}
+
+// fenceOpener returns the code fence, and language tag if present, which opens a code fence on line 'ln'. Any
+// other text on the line is omitted since the opener is repeated at the start of each chunk the fence spans.
+func fenceOpener(ln string) string {
+
+	rest := strings.TrimPrefix(strings.TrimSpace(ln), code_fence)
+
+	end := strings.IndexFunc(rest, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '`'
+	})
+
+	if end == -1 {
+		end = len(rest)
+	}
+
+	return code_fence + rest[:end]
+}
+
+// chunker accumulates paragraphs and lines in to chunks of at most 'limit' characters. Once the first chunk has
+// been flushed 'limit' is replaced by 'next'.
+type chunker struct {
+	limit   int
+	next    int
+	chunks  []string
+	current strings.Builder
+	length  int
+}
+
+func (c *chunker) addParagraph(p string) {
+
+	p_len := utf8.RuneCountInString(p)
+
+	sep_len := 0
+
+	if c.length > 0 {
+		sep_len = 2
+	}
+
+	if c.length+sep_len+p_len <= c.limit {
+		c.append(p, "\n\n")
+		return
+	}
+
+	if p_len <= c.limit {
+		c.flush("")
+		c.append(p, "")
+		return
+	}
+
+	c.flush("")
+
+	// The paragraph is too long to fit in a single chunk so split it by line, keeping track
+	// of whether or not we are inside a code fence.
+
+	opener := ""
+
+	for _, ln := range strings.Split(p, "\n") {
+
+		after := opener
+
+		if isFenceLine(ln) {
+
+			if opener == "" {
+				after = fenceOpener(ln)
+			} else {
+				after = ""
+			}
+		}
+
+		c.addLine(ln, opener, after)
+		opener = after
+	}
+}
+
+// addLine adds 'ln' to the current chunk. 'opener' is the code fence enclosing the line, if any, and 'after' is
+// the code fence which will be open after the line has been added.
+func (c *chunker) addLine(ln string, opener string, after string) {
+
+	ln_len := utf8.RuneCountInString(ln)
+
+	close_len := 0
+
+	if after != "" {
+		close_len = len(code_fence) + 1
+	}
+
+	sep_len := 0
+
+	if c.length > 0 {
+		sep_len = 1
+	}
+
+	if c.length+sep_len+ln_len+close_len <= c.limit {
+		c.append(ln, "\n")
+		return
+	}
+
+	if c.length > 0 {
+		c.flush(opener)
+	}
+
+	if opener != "" {
+		c.append(opener, "")
+	}
+
+	if c.length+1+ln_len+close_len <= c.limit {
+		c.append(ln, "\n")
+		return
+	}
+
+	// The line is too long to fit in a single chunk so split it in to pieces. The budget is derived
+	// for each piece since the limit for the first chunk may be smaller than the others.
+
+	for idx := 0; ln != ""; idx++ {
+
+		if idx > 0 {
+			c.flush(opener)
+
+			if opener != "" {
+				c.append(opener, "")
+			}
+		}
+
+		budget := c.limit - close_len
+
+		if c.length > 0 {
+			budget -= c.length + 1
+		}
+
+		pieces := splitLine(ln, budget)
+
+		c.append(pieces[0], "\n")
+		ln = strings.Join(pieces[1:], "")
+	}
+}
+
+func (c *chunker) append(str string, sep string) {
+
+	if c.length > 0 {
+		c.current.WriteString(sep)
+		c.length += utf8.RuneCountInString(sep)
+	}
+
+	c.current.WriteString(str)
+	c.length += utf8.RuneCountInString(str)
+}
+
+// flush appends the current chunk to the list of chunks, closing the code fence if 'opener' is not empty.
+func (c *chunker) flush(opener string) {
+
+	if c.length == 0 {
+		return
+	}
+
+	if opener != "" {
+		c.current.WriteString("\n")
+		c.current.WriteString(code_fence)
+	}
+
+	c.chunks = append(c.chunks, c.current.String())
+	c.current.Reset()
+	c.length = 0
+
+	if c.next > 0 {
+		c.limit = c.next
+	}
+}
+
+// splitLine splits 'ln' in to pieces of at most 'limit' characters, preferring to split on whitespace and
+// never splitting an escaped entity (for example "&amp;") or a mention token (for example "<@U123456>").
+func splitLine(ln string, limit int) []string {
+
+	if limit < 1 {
+		limit = 1
+	}
+
+	pieces := make([]string, 0)
+	r := []rune(ln)
+
+	for len(r) > limit {
+
+		cut := limit
+
+		if idx := lastIndexRune(r[:cut], ' '); idx > limit/2 {
+			cut = idx + 1
+		}
+
+		if idx := lastIndexRune(r[:cut], '&'); idx > 0 && lastIndexRune(r[idx:cut], ';') == -1 && cut-idx < 10 {
+			cut = idx
+		}
+
+		if idx := lastIndexRune(r[:cut], '<'); idx > 0 && lastIndexRune(r[idx:cut], '>') == -1 {
+			cut = idx
+		}
+
+		pieces = append(pieces, string(r[:cut]))
+		r = r[cut:]
+	}
+
+	if len(r) > 0 {
+		pieces = append(pieces, string(r))
+	}
+
+	return pieces
+}
+
+func lastIndexRune(r []rune, c rune) int {
+
+	for i := len(r) - 1; i >= 0; i-- {
+
+		if r[i] == c {
+			return i
+		}
+	}
+
+	return -1
+}
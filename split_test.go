@@ -0,0 +1,95 @@
+package slack
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitText(t *testing.T) {
+
+	tests := map[string]struct {
+		text     string
+		limit    int
+		expected []string
+	}{
+		"short": {
+			text:     "Hello world",
+			limit:    20,
+			expected: []string{"Hello world"},
+		},
+		"paragraphs": {
+			text:     "para one\n\n```\nl1\nl2\nl3\n```\n\npara two",
+			limit:    20,
+			expected: []string{"para one", "```\nl1\nl2\nl3\n```", "para two"},
+		},
+		"words": {
+			text:     "one two three four five six",
+			limit:    10,
+			expected: []string{"one two ", "three four", " five six"},
+		},
+		"fence reopened": {
+			text:     "```\nline one\nline two\nline three\n```",
+			limit:    25,
+			expected: []string{"```\nline one\nline two\n```", "```\nline three\n```"},
+		},
+		"fence reopened with language": {
+			text:     "```go\nfunc a() {}\nfunc b() {}\nfunc c() {}\n```",
+			limit:    30,
+			expected: []string{"```go\nfunc a() {}\n```", "```go\nfunc b() {}\n```", "```go\nfunc c() {}\n```"},
+		},
+		"fence reopened without info string": {
+			text:     "```go title=\"x\"\nline one\nline two\nline three\n```",
+			limit:    30,
+			expected: []string{"```go title=\"x\"\nline one\n```", "```go\nline two\nline three\n```"},
+		},
+		"blank lines inside fence": {
+			text:     "```\na\n\nb\n```\n\nafter the fence",
+			limit:    16,
+			expected: []string{"```\na\n\nb\n```", "after the fence"},
+		},
+	}
+
+	for name, tc := range tests {
+
+		t.Run(name, func(t *testing.T) {
+
+			got := splitText(tc.text, tc.limit)
+
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Fatalf("Expected %q but got %q", tc.expected, got)
+			}
+
+			for idx, chunk := range got {
+
+				if utf8.RuneCountInString(chunk) > tc.limit {
+					t.Fatalf("Chunk %d is longer than %d characters, %q", idx, tc.limit, chunk)
+				}
+
+				if strings.Count(chunk, code_fence)%2 != 0 {
+					t.Fatalf("Chunk %d has an unclosed code fence, %q", idx, chunk)
+				}
+			}
+		})
+	}
+}
+
+func TestFenceOpener(t *testing.T) {
+
+	tests := map[string]string{
+		"```":               "```",
+		"  ```go":           "```go",
+		"```python title=x": "```python",
+		"```js```":          "```js",
+	}
+
+	for ln, expected := range tests {
+
+		got := fenceOpener(ln)
+
+		if got != expected {
+			t.Fatalf("Expected %q for %q but got %q", expected, ln, got)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"net/url"
+	"regexp"
+)
+
+// Matches Slack workspace (team) IDs.
+var re_team_id = regexp.MustCompile(`^T[A-Z0-9]{6,}$`)
+
+// validateTeamID ensures that 'team' is a valid Slack workspace ID.
+func validateTeamID(team string) error {
+
+	if !re_team_id.MatchString(team) {
+		return fmt.Errorf("Invalid team ID '%s'", team)
+	}
+
+	return nil
+}
+
+// withTeam returns a copy of 'args' with the "team_id" argument set to the broadcaster's workspace, if it has one
+// and 'args' does not already define one.
+func (br *SlackBroadcaster) withTeam(args url.Values) url.Values {
+
+	if br.team == "" || args.Has("team_id") {
+		return args
+	}
+
+	team_args := url.Values{}
+
+	for k, v := range args {
+		team_args[k] = v
+	}
+
+	team_args.Set("team_id", br.team)
+	return team_args
+}
+
+// teamError returns 'err', an error returned by an API method which posts to the broadcaster's channel, with an
+// explanation added if it was caused by the channel, or the app, not belonging to the broadcaster's workspace.
+func (br *SlackBroadcaster) teamError(ctx context.Context, err error) error {
+
+	if br.team == "" {
+
+		if IsAPIError(err, "missing_argument", "team_not_found") {
+			return fmt.Errorf("Organization-wide tokens must specify a workspace using the ?team= parameter, %w", err)
+		}
+
+		return err
+	}
+
+	if IsAPIError(err, "team_access_not_granted", "invalid_team_id", "team_not_found") {
+		return fmt.Errorf("The app has not been granted access to workspace %s, %w", br.team, err)
+	}
+
+	if !IsAPIError(err, "channel_not_found", "not_in_channel") {
+		return err
+	}
+
+	// Channel names are resolved within the workspace so only channel IDs can be traced to another workspace
+
+	if !re_channel_id.MatchString(br.channel) {
+		return fmt.Errorf("Channel '%s' was not found in workspace %s, %w", br.channel, br.team, err)
+	}
+
+	args := url.Values{}
+	args.Set("channel", br.channel)
+
+	body, info_err := br.api(ctx, "conversations.info", args)
+
+	if info_err != nil {
+		return fmt.Errorf("Channel '%s' was not found in workspace %s, %w", br.channel, br.team, err)
+	}
+
+	teams := channelTeams(body)
+
+	for _, t := range teams {
+
+		if t == br.team {
+			return fmt.Errorf("The app is not a member of channel '%s' in workspace %s, %w", br.channel, br.team, err)
+		}
+	}
+
+	if len(teams) == 0 {
+		return fmt.Errorf("Channel '%s' was not found in workspace %s, %w", br.channel, br.team, err)
+	}
+
+	return fmt.Errorf("Channel '%s' belongs to workspace %s, not %s, %w", br.channel, teams[0], br.team, err)
+}
+
+// channelTeams returns the IDs of the workspaces that the channel described by a conversations.info API response
+// belongs to, starting with the workspace it was created in.
+func channelTeams(body []byte) []string {
+
+	teams := make([]string, 0)
+	seen := make(map[string]bool)
+
+	add := func(t string) {
+
+		if t != "" && !seen[t] {
+			teams = append(teams, t)
+			seen[t] = true
+		}
+	}
+
+	add(gjson.GetBytes(body, "channel.context_team_id").String())
+
+	for _, t := range gjson.GetBytes(body, "channel.shared_team_ids").Array() {
+		add(t.String())
+	}
+
+	return teams
+}
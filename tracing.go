@@ -0,0 +1,70 @@
+package slack
+
+import (
+	"fmt"
+	"go.opencensus.io/trace"
+	"net/http"
+)
+
+// Attribute keys for the OpenCensus trace spans created by this package.
+const (
+	ATTR_CHANNEL        string = "slack.channel"
+	ATTR_METHOD         string = "slack.method"
+	ATTR_ATTEMPT        string = "slack.attempt"
+	ATTR_ERROR          string = "slack.error"
+	ATTR_IMAGES         string = "slack.images"
+	ATTR_MESSAGE_ID     string = "slack.message_id"
+	ATTR_FILENAME       string = "slack.filename"
+	ATTR_BYTES          string = "slack.bytes"
+	ATTR_RATE_LIMITED   string = "slack.rate_limit_wait_ms"
+	ATTR_HTTP_STATUS    string = "http.status_code"
+	ATTR_REQUEST_BYTES  string = "http.request_bytes"
+	ATTR_RESPONSE_BYTES string = "http.response_bytes"
+)
+
+// endSpan sets the status of 'span' according to 'err' and ends it.
+func endSpan(span *trace.Span, err error) {
+
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: redactString(err.Error())})
+	}
+
+	span.End()
+}
+
+// traceAPICall adds the attributes of 'call' to 'span' and ends it.
+func traceAPICall(span *trace.Span, call *apiCall) {
+
+	attrs := []trace.Attribute{
+		trace.StringAttribute(ATTR_METHOD, call.method),
+		trace.Int64Attribute(ATTR_ATTEMPT, int64(call.attempt)),
+		trace.Int64Attribute(ATTR_REQUEST_BYTES, call.request_bytes),
+	}
+
+	if call.status != 0 {
+		attrs = append(attrs, trace.Int64Attribute(ATTR_HTTP_STATUS, int64(call.status)), trace.Int64Attribute(ATTR_RESPONSE_BYTES, int64(call.response_bytes)))
+	}
+
+	if call.code != "" {
+		attrs = append(attrs, trace.StringAttribute(ATTR_ERROR, call.code))
+	}
+
+	if call.wait > 0 {
+		attrs = append(attrs, trace.Int64Attribute(ATTR_RATE_LIMITED, call.wait.Milliseconds()))
+	}
+
+	span.AddAttributes(attrs...)
+
+	switch {
+	case call.err != nil:
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: redactString(call.err.Error())})
+	case call.status == http.StatusTooManyRequests:
+		span.SetStatus(trace.Status{Code: trace.StatusCodeResourceExhausted, Message: "Rate limited"})
+	case call.status != http.StatusOK:
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: fmt.Sprintf("HTTP status %d", call.status)})
+	case call.code != "":
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: call.code})
+	}
+
+	span.End()
+}
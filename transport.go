@@ -0,0 +1,117 @@
+package slack
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// The default amount of time to wait for a Slack API request, including reading the response body, to complete.
+const default_http_timeout time.Duration = 30 * time.Second
+
+// NO_TIMEOUT is the value of `Options.Timeout` which signals that API requests should never time out.
+const NO_TIMEOUT time.Duration = -1
+
+// newHTTPClient returns a new `http.Client` which times out after 'timeout' (or never if zero), sends requests through
+// 'proxy' (if not empty) and trusts the certificates in the file at 'ca_bundle' (if not empty).
+func newHTTPClient(timeout time.Duration, proxy string, ca_bundle string) (*http.Client, error) {
+
+	if timeout < 0 {
+		return nil, fmt.Errorf("Invalid timeout, must not be negative")
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxy != "" {
+
+		proxy_url, err := url.Parse(proxy)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid proxy, %w", err)
+		}
+
+		switch proxy_url.Scheme {
+		case "http", "https", "socks5":
+			// pass
+		default:
+			return nil, fmt.Errorf("Invalid proxy, unsupported scheme '%s'", proxy_url.Scheme)
+		}
+
+		tr.Proxy = http.ProxyURL(proxy_url)
+	}
+
+	if ca_bundle != "" {
+
+		pool, err := newCertPool(ca_bundle)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CA bundle, %w", err)
+		}
+
+		tr.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	cl := &http.Client{
+		Transport: tr,
+		Timeout:   timeout,
+	}
+
+	return cl, nil
+}
+
+// newCertPool returns the system certificate pool with the PEM-encoded certificates in the file at 'path' added to it.
+func newCertPool(path string) (*x509.CertPool, error) {
+
+	body, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read CA bundle, %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(body) {
+		return nil, fmt.Errorf("CA bundle does not contain any valid certificates")
+	}
+
+	return pool, nil
+}
+
+// SetHTTPClient assigns 'cl' as the `http.Client` used by 'br' to make API requests. The timeout and transport
+// settings defined by the broadcaster URI are not applied to 'cl'.
+func (br *SlackBroadcaster) SetHTTPClient(cl *http.Client) error {
+
+	if cl == nil {
+		return fmt.Errorf("Missing HTTP client")
+	}
+
+	br.http_client = cl
+	return nil
+}
+
+// SetTransport assigns 'rt' as the `http.RoundTripper` used by 'br' to make API requests, replacing any proxy
+// or CA bundle settings defined by the broadcaster URI. The request timeout is preserved.
+func (br *SlackBroadcaster) SetTransport(rt http.RoundTripper) error {
+
+	if rt == nil {
+		return fmt.Errorf("Missing transport")
+	}
+
+	br.http_client = &http.Client{
+		Transport: rt,
+		Timeout:   br.http_client.Timeout,
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package slack
+
+import (
+	"context"
+	"fmt"
+	"github.com/aaronland/go-uid"
+	"strings"
+)
+
+// MessageUID implements the `uid.UID` interface for a message posted to a Slack channel. Slack
+// messages are uniquely identified by the ID of the channel they were posted to and their timestamp.
+type MessageUID struct {
+	uid.UID
+	channel string
+	ts      string
+}
+
+// NewMessageUID returns a new `MessageUID` instance for the message posted to 'channel' with timestamp 'ts'.
+func NewMessageUID(ctx context.Context, channel string, ts string) (uid.UID, error) {
+
+	if channel == "" {
+		return nil, fmt.Errorf("Missing channel")
+	}
+
+	if ts == "" {
+		return nil, fmt.Errorf("Missing timestamp")
+	}
+
+	u := &MessageUID{
+		channel: channel,
+		ts:      ts,
+	}
+
+	return u, nil
+}
+
+// ParseMessageUID returns a new `MessageUID` instance derived from 'str' which is expected to take
+// the form of "{CHANNEL_ID}/{TIMESTAMP}", as returned by the `MessageUID.String` method. The prefixed string values
+// of other UIDs, for example "scheduled:{CHANNEL_ID}/{SCHEDULED_MESSAGE_ID}", are not valid message UIDs.
+func ParseMessageUID(ctx context.Context, str string) (uid.UID, error) {
+
+	parts := strings.Split(str, "/")
+
+	if len(parts) != 2 || strings.Contains(parts[0], ":") {
+		return nil, fmt.Errorf("Invalid message UID '%s'", str)
+	}
+
+	return NewMessageUID(ctx, parts[0], parts[1])
+}
+
+// Channel returns the ID of the channel that the message was posted to.
+func (u *MessageUID) Channel() string {
+	return u.channel
+}
+
+// Timestamp returns the Slack timestamp ("ts") of the message.
+func (u *MessageUID) Timestamp() string {
+	return u.ts
+}
+
+// Value returns the string representation of 'u'.
+func (u *MessageUID) Value() any {
+	return u.String()
+}
+
+// String returns 'u' in the form of "{CHANNEL_ID}/{TIMESTAMP}".
+func (u *MessageUID) String() string {
+	return fmt.Sprintf("%s/%s", u.channel, u.ts)
+}
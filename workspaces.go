@@ -0,0 +1,449 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/aaronland/go-broadcaster"
+	"github.com/aaronland/go-uid"
+	"github.com/sfomuseum/runtimevar"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	ctx := context.Background()
+	broadcaster.RegisterBroadcaster(ctx, "slack-workspaces", NewWorkspacesBroadcaster)
+}
+
+// WorkspacesConfig defines the Slack workspaces, and the channels in each workspace, that a `WorkspacesBroadcaster`
+// posts messages to.
+type WorkspacesConfig struct {
+	// Workspaces maps a name for each workspace to its configuration.
+	Workspaces map[string]*WorkspaceConfig `json:"workspaces" yaml:"workspaces"`
+}
+
+// WorkspaceConfig defines the credentials and destinations for a single Slack workspace.
+type WorkspaceConfig struct {
+	// A valid `sfomuseum/runtimevar` URI which dereferences to a Slack API OAuth token for the workspace.
+	Credentials string `json:"credentials" yaml:"credentials"`
+	// The ID of the workspace, for organization-wide (Enterprise Grid) tokens.
+	Team string `json:"team,omitempty" yaml:"team,omitempty"`
+	// Query parameters, as supported by `NewSlackBroadcaster`, applied to every destination in the workspace.
+	Parameters map[string]string `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	// The channels to post messages to, each taking the form of "{CHANNEL}" or "{CHANNEL}?{PARAMETERS}" where
+	// {PARAMETERS} override the workspace parameters for that channel.
+	Destinations []string `json:"destinations" yaml:"destinations"`
+}
+
+// NewWorkspacesConfig returns a new `WorkspacesConfig` instance decoded from the JSON or YAML document in 'r'.
+// Documents whose first non-whitespace character is "{" are decoded as JSON and all others as YAML.
+func NewWorkspacesConfig(ctx context.Context, r io.Reader) (*WorkspacesConfig, error) {
+
+	body, err := io.ReadAll(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read config, %w", err)
+	}
+
+	var cfg *WorkspacesConfig
+
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+
+		err = dec.Decode(&cfg)
+
+	} else {
+
+		dec := yaml.NewDecoder(bytes.NewReader(body))
+		dec.KnownFields(true)
+
+		err = dec.Decode(&cfg)
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("Failed to decode config, %w", err)
+	}
+
+	if cfg == nil || len(cfg.Workspaces) == 0 {
+		return nil, fmt.Errorf("Config does not define any workspaces")
+	}
+
+	for name, ws := range cfg.Workspaces {
+
+		if ws == nil || ws.Credentials == "" {
+			return nil, fmt.Errorf("Workspace '%s' is missing credentials", name)
+		}
+
+		if len(ws.Destinations) == 0 {
+			return nil, fmt.Errorf("Workspace '%s' does not define any destinations", name)
+		}
+	}
+
+	return cfg, nil
+}
+
+// destinationURIs returns a map of destination names, in the form of "{WORKSPACE}#{CHANNEL}", to the `slack://`
+// URIs used to post messages to them.
+func (cfg *WorkspacesConfig) destinationURIs() (map[string]string, error) {
+
+	uris := make(map[string]string)
+
+	for name, ws := range cfg.Workspaces {
+
+		for _, dest := range ws.Destinations {
+
+			channel, raw_q, _ := strings.Cut(dest, "?")
+			channel = strings.TrimPrefix(channel, "#")
+
+			if channel == "" {
+				return nil, fmt.Errorf("Invalid destination '%s' in workspace '%s'", dest, name)
+			}
+
+			dest_q, err := url.ParseQuery(raw_q)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid destination '%s' in workspace '%s', %w", dest, name, err)
+			}
+
+			q := url.Values{}
+
+			for k, v := range ws.Parameters {
+				q.Set(k, v)
+			}
+
+			for k, v := range dest_q {
+				q[k] = v
+			}
+
+			q.Set("credentials", ws.Credentials)
+
+			if ws.Team != "" {
+				q.Set("team", ws.Team)
+			}
+
+			key := fmt.Sprintf("%s#%s", name, channel)
+
+			if _, exists := uris[key]; exists {
+				return nil, fmt.Errorf("Duplicate destination '%s'", key)
+			}
+
+			uris[key] = fmt.Sprintf("slack://%s?%s", channel, q.Encode())
+		}
+	}
+
+	return uris, nil
+}
+
+// DestinationResult is the outcome of posting a message to a single destination.
+type DestinationResult struct {
+	// The name of the destination. For a `WorkspacesBroadcaster` this takes the form of "{WORKSPACE}#{CHANNEL}" and
+	// for a `RouteBroadcaster` it is the name of the channel.
+	Destination string
+	// The UID of the posted message, if successful.
+	UID uid.UID
+	// The error that occurred posting the message, if any.
+	Err error
+}
+
+// WorkspacesUID implements the `uid.UID` interface for a message posted to one or more destinations by
+// a `WorkspacesBroadcaster`.
+type WorkspacesUID struct {
+	uid.UID
+	results []*DestinationResult
+}
+
+// Results returns the outcome for each destination, sorted by destination name.
+func (u *WorkspacesUID) Results() []*DestinationResult {
+	return u.results
+}
+
+// Value returns the list of `DestinationResult` instances for 'u'.
+func (u *WorkspacesUID) Value() any {
+	return u.results
+}
+
+// String returns 'u' in the form of "{DESTINATION}={UID}" for each destination, separated by spaces. Failed
+// destinations are rendered as "{DESTINATION}=error".
+func (u *WorkspacesUID) String() string {
+	return resultsString(u.results)
+}
+
+// namedBroadcaster is a named destination and the broadcaster used to post messages to it.
+type namedBroadcaster struct {
+	name        string
+	broadcaster broadcaster.Broadcaster
+}
+
+// WorkspacesBroadcaster implements the `broadcaster.Broadcaster` interface by posting messages, concurrently, to
+// channels in one or more Slack workspaces each with their own credentials.
+type WorkspacesBroadcaster struct {
+	broadcaster.Broadcaster
+	destinations []*namedBroadcaster
+	logger       *log.Logger
+}
+
+// NewWorkspacesBroadcaster returns a new `WorkspacesBroadcaster` configured by 'uri' which is expected to take
+// the form of:
+//
+//	slack-workspaces://?config={RUNTIMEVAR_URI}
+//
+// Where {RUNTIMEVAR_URI} is a valid `sfomuseum/runtimevar` URI which dereferences to a JSON or YAML encoded
+// `WorkspacesConfig` document.
+func NewWorkspacesBroadcaster(ctx context.Context, uri string) (broadcaster.Broadcaster, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	q := u.Query()
+
+	config_uri := q.Get("config")
+
+	if config_uri == "" {
+		return nil, fmt.Errorf("Missing ?config= parameter")
+	}
+
+	rt_ctx, rt_cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer rt_cancel()
+
+	body, err := runtimevar.StringVar(rt_ctx, config_uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to derive config, %w", err)
+	}
+
+	cfg, err := NewWorkspacesConfig(ctx, strings.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewWorkspacesBroadcasterWithConfig(ctx, cfg)
+}
+
+// NewWorkspacesBroadcasterWithConfig returns a new `WorkspacesBroadcaster` which posts messages to each of
+// the destinations defined by 'cfg'.
+func NewWorkspacesBroadcasterWithConfig(ctx context.Context, cfg *WorkspacesConfig) (*WorkspacesBroadcaster, error) {
+
+	uris, err := cfg.destinationURIs()
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+
+	for name := range uris {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	destinations := make([]*namedBroadcaster, 0, len(names))
+
+	// Destinations in the same workspace share credentials which are only dereferenced once
+
+	tokens := make(map[string]string)
+
+	for _, name := range names {
+
+		br, err := newWorkspaceBroadcaster(ctx, uris[name], tokens)
+
+		if err != nil {
+
+			// Stop any outbox or digest goroutines started by the destinations already created
+
+			close_err := closeDestinations(destinations)
+
+			if close_err != nil {
+				err = errors.Join(err, close_err)
+			}
+
+			return nil, fmt.Errorf("Failed to create broadcaster for %s, %w", name, err)
+		}
+
+		destinations = append(destinations, &namedBroadcaster{
+			name:        name,
+			broadcaster: br,
+		})
+	}
+
+	br := &WorkspacesBroadcaster{
+		destinations: destinations,
+		logger:       log.Default(),
+	}
+
+	return br, nil
+}
+
+// newWorkspaceBroadcaster returns a new broadcaster for the `slack://` URI 'uri' reading, and adding to, 'tokens' when
+// dereferencing its credentials.
+func newWorkspaceBroadcaster(ctx context.Context, uri string, tokens map[string]string) (broadcaster.Broadcaster, error) {
+
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse URI, %w", err)
+	}
+
+	opts, err := newOptionsFromURL(ctx, u, tokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSlackBroadcasterWithOptions(ctx, opts)
+}
+
+// BroadcastMessage posts 'msg' to every destination concurrently and returns a `WorkspacesUID` with the outcome
+// for each destination. If any destination fails a non-nil `WorkspacesUID` is still returned, along with an error
+// which wraps each failure.
+func (br *WorkspacesBroadcaster) BroadcastMessage(ctx context.Context, msg *broadcaster.Message) (uid.UID, error) {
+
+	results, err := broadcastAll(ctx, br.destinations, msg)
+
+	u := &WorkspacesUID{
+		results: results,
+	}
+
+	return u, err
+}
+
+// BroadcastSlackMessage posts 'msg', and its Slack-specific properties, to every destination in the same way as the
+// `BroadcastMessage` method.
+func (br *WorkspacesBroadcaster) BroadcastSlackMessage(ctx context.Context, msg *Message) (uid.UID, error) {
+	return broadcastSlackMessage(ctx, br, msg)
+}
+
+// SetLogger assigns 'logger' to 'br' and the broadcasters for each of its destinations.
+func (br *WorkspacesBroadcaster) SetLogger(ctx context.Context, logger *log.Logger) error {
+
+	br.logger = logger
+
+	for _, d := range br.destinations {
+
+		err := d.broadcaster.SetLogger(ctx, logger)
+
+		if err != nil {
+			return fmt.Errorf("Failed to set logger for %s, %w", d.name, err)
+		}
+	}
+
+	return nil
+}
+
+// Destinations returns the names of the destinations that 'br' posts messages to, in the form of "{WORKSPACE}#{CHANNEL}".
+func (br *WorkspacesBroadcaster) Destinations() []string {
+
+	names := make([]string, len(br.destinations))
+
+	for idx, d := range br.destinations {
+		names[idx] = d.name
+	}
+
+	return names
+}
+
+// Close closes the broadcasters for each destination which need to be closed, for example those with an outbox
+// or a digest interval.
+func (br *WorkspacesBroadcaster) Close() error {
+	return closeDestinations(br.destinations)
+}
+
+// closeDestinations closes the broadcasters for each of 'destinations' which need to be closed and returns an error
+// which wraps each failure.
+func closeDestinations(destinations []*namedBroadcaster) error {
+
+	errs := make([]error, 0)
+
+	for _, d := range destinations {
+
+		cl, ok := d.broadcaster.(io.Closer)
+
+		if !ok {
+			continue
+		}
+
+		err := cl.Close()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed to close broadcaster for %s, %w", d.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// broadcastAll posts 'msg' to each of 'destinations' concurrently and returns the outcome for each, in the same order,
+// and an error which wraps each failure if any destination failed.
+func broadcastAll(ctx context.Context, destinations []*namedBroadcaster, msg *broadcaster.Message) ([]*DestinationResult, error) {
+
+	results := make([]*DestinationResult, len(destinations))
+
+	wg := new(sync.WaitGroup)
+
+	for idx, d := range destinations {
+
+		wg.Add(1)
+
+		go func(idx int, d *namedBroadcaster) {
+
+			defer wg.Done()
+
+			id, err := d.broadcaster.BroadcastMessage(ctx, msg)
+
+			results[idx] = &DestinationResult{
+				Destination: d.name,
+				UID:         id,
+				Err:         err,
+			}
+
+		}(idx, d)
+	}
+
+	wg.Wait()
+
+	errs := make([]error, 0)
+
+	for _, r := range results {
+
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("Failed to broadcast message to %s, %w", r.Destination, r.Err))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// resultsString returns 'results' in the form of "{DESTINATION}={UID}" for each destination, separated by spaces.
+// Failed destinations are rendered as "{DESTINATION}=error".
+func resultsString(results []*DestinationResult) string {
+
+	pairs := make([]string, len(results))
+
+	for idx, r := range results {
+
+		v := "error"
+
+		if r.Err == nil {
+			v = r.UID.String()
+		}
+
+		pairs[idx] = fmt.Sprintf("%s=%s", r.Destination, v)
+	}
+
+	return strings.Join(pairs, " ")
+}